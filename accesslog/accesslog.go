@@ -0,0 +1,304 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"load-balancer/lb"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Supported formats.
+const (
+	FormatJSON     = "json"     // one JSON object per request
+	FormatCommon   = "common"   // NCSA Common Log Format
+	FormatCombined = "combined" // Common Log Format with referer and user agent
+	FormatTemplate = "template" // text/template executed on the Entry
+)
+
+// clfTime is the timestamp layout of the Common Log Format.
+const clfTime = "02/Jan/2006:15:04:05 -0700"
+
+// Entry is a single access log record.
+type Entry struct {
+	Time             time.Time     // when the request was received
+	RequestID        string        // X-Request-ID of the request
+	ClientIP         string        // address of the client
+	Method           string        // request method
+	Path             string        // request URI, with the query
+	Proto            string        // protocol, e.g. HTTP/1.1
+	Host             string        // Host header
+	User             string        // basic auth user, empty without authentication
+	Status           int           // status sent to the client
+	Bytes            int64         // response body bytes sent to the client
+	Duration         time.Duration // total time, from the request until the response was sent
+	UpstreamDuration time.Duration // time spent in the backend of the last attempt
+	Backend          string        // url of the backend of the last attempt, empty if none was available
+	Retries          int           // attempts after the first
+	Referer          string        // Referer header
+	UserAgent        string        // User-Agent header
+}
+
+// jsonEntry is the JSON representation of an Entry, durations in seconds.
+type jsonEntry struct {
+	Time             string  `json:"time"`
+	RequestID        string  `json:"request_id"`
+	ClientIP         string  `json:"client_ip"`
+	Method           string  `json:"method"`
+	Path             string  `json:"path"`
+	Proto            string  `json:"proto"`
+	Host             string  `json:"host"`
+	User             string  `json:"user,omitempty"`
+	Status           int     `json:"status"`
+	Bytes            int64   `json:"bytes"`
+	Duration         float64 `json:"duration"`
+	UpstreamDuration float64 `json:"upstream_duration"`
+	Backend          string  `json:"backend"`
+	Retries          int     `json:"retries"`
+	Referer          string  `json:"referer,omitempty"`
+	UserAgent        string  `json:"user_agent,omitempty"`
+}
+
+// Options configures an access logger.
+type Options struct {
+	Format   string    // json (default), common, combined or template
+	Template string    // text/template on the Entry, required by the template format
+	Output   io.Writer // destination of the records
+}
+
+// Logger writes an access log record for every request.
+type Logger struct {
+	mux    sync.Mutex // keeps records whole on the output
+	out    io.Writer
+	format func(buf *bytes.Buffer, e *Entry) error
+}
+
+// New creates an access logger with the provided options.
+// Returns an error if the format is unknown or the template does not parse.
+func New(opts Options) (*Logger, error) {
+	if opts.Output == nil {
+		return nil, errors.New("access log output expected, none provided")
+	}
+
+	l := &Logger{out: opts.Output}
+
+	switch opts.Format {
+	case "", FormatJSON:
+		l.format = formatJSON
+	case FormatCommon:
+		l.format = formatCommon
+	case FormatCombined:
+		l.format = formatCombined
+	case FormatTemplate:
+		if opts.Template == "" {
+			return nil, errors.New("access log template expected, none provided")
+		}
+		tmpl, err := template.New("access_log").Parse(opts.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid access log template: %w", err)
+		}
+		l.format = func(buf *bytes.Buffer, e *Entry) error {
+			return tmpl.Execute(buf, e)
+		}
+	default:
+		return nil, fmt.Errorf("unknown access log format %q", opts.Format)
+	}
+
+	return l, nil
+}
+
+// Middleware logs every request served by the load balancer handler.
+// The backend, attempts and upstream duration are read from the lb.RequestInfo attached to the request.
+func (l *Logger) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		r, info := lb.WithRequestInfo(r)
+		rw := &responseWriter{ResponseWriter: w}
+		next.ServeHTTP(rw, r)
+
+		user, _, _ := r.BasicAuth()
+		l.Log(&Entry{
+			Time:             start,
+			RequestID:        info.RequestID,
+			ClientIP:         clientIP(r, info),
+			Method:           r.Method,
+			Path:             r.RequestURI,
+			Proto:            r.Proto,
+			Host:             r.Host,
+			User:             user,
+			Status:           rw.statusCode(),
+			Bytes:            rw.bytes,
+			Duration:         time.Since(start),
+			UpstreamDuration: info.UpstreamDuration,
+			Backend:          info.Backend,
+			Retries:          info.Retries(),
+			Referer:          r.Referer(),
+			UserAgent:        r.UserAgent(),
+		})
+	})
+}
+
+// Log writes the record of a request. A record that fails to format is dropped.
+func (l *Logger) Log(e *Entry) {
+	var buf bytes.Buffer
+	if err := l.format(&buf, e); err != nil {
+		return
+	}
+	if buf.Len() == 0 || buf.Bytes()[buf.Len()-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	_, _ = l.out.Write(buf.Bytes())
+}
+
+func formatJSON(buf *bytes.Buffer, e *Entry) error {
+	return json.NewEncoder(buf).Encode(jsonEntry{
+		Time:             e.Time.Format(time.RFC3339Nano),
+		RequestID:        e.RequestID,
+		ClientIP:         e.ClientIP,
+		Method:           e.Method,
+		Path:             e.Path,
+		Proto:            e.Proto,
+		Host:             e.Host,
+		User:             e.User,
+		Status:           e.Status,
+		Bytes:            e.Bytes,
+		Duration:         e.Duration.Seconds(),
+		UpstreamDuration: e.UpstreamDuration.Seconds(),
+		Backend:          e.Backend,
+		Retries:          e.Retries,
+		Referer:          e.Referer,
+		UserAgent:        e.UserAgent,
+	})
+}
+
+// formatCommon writes: host ident authuser [time] "request" status bytes
+func formatCommon(buf *bytes.Buffer, e *Entry) error {
+	buf.WriteString(orDash(e.ClientIP))
+	buf.WriteString(" - ")
+	buf.WriteString(orDash(e.User))
+	buf.WriteString(" [")
+	buf.WriteString(e.Time.Format(clfTime))
+	buf.WriteString(`] "`)
+	buf.WriteString(escape(e.Method + " " + e.Path + " " + e.Proto))
+	buf.WriteString(`" `)
+	buf.WriteString(strconv.Itoa(e.Status))
+	buf.WriteByte(' ')
+	if e.Bytes > 0 {
+		buf.WriteString(strconv.FormatInt(e.Bytes, 10))
+	} else {
+		buf.WriteByte('-')
+	}
+	buf.WriteByte('\n')
+	return nil
+}
+
+// formatCombined writes the Common Log Format followed by "referer" "user agent".
+func formatCombined(buf *bytes.Buffer, e *Entry) error {
+	_ = formatCommon(buf, e)
+	buf.Truncate(buf.Len() - 1) // newline
+	fmt.Fprintf(buf, " \"%s\" \"%s\"\n", escape(orDash(e.Referer)), escape(orDash(e.UserAgent)))
+	return nil
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// escape quotes, backslashes and control characters so a field cannot break the line.
+func escape(s string) string {
+	quoted := strconv.Quote(s)
+	return quoted[1 : len(quoted)-1]
+}
+
+// clientIP returns the client resolved by the load balancer, the address of the client connection if none was.
+func clientIP(r *http.Request, info *lb.RequestInfo) string {
+	if info.ClientIP != "" {
+		return info.ClientIP
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// responseWriter records the status and body size of the response.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	// Informational responses are followed by the final status
+	if w.status == 0 && code >= http.StatusOK {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush lets streamed responses through the wrapper.
+func (w *responseWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// statusCode returns the status sent to the client, 200 if the handler wrote nothing.
+func (w *responseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// ParseOutput returns the writer of an output setting: "stdout" (default), "stderr" or a file path.
+// Files are appended to and rotated once they exceed maxSize bytes, keeping maxBackups rotated files.
+func ParseOutput(output string, maxSize int64, maxBackups int) (io.WriteCloser, error) {
+	switch strings.ToLower(output) {
+	case "", "stdout":
+		return nopCloser{Writer: os.Stdout}, nil
+	case "stderr":
+		return nopCloser{Writer: os.Stderr}, nil
+	default:
+		return NewRotatingFile(output, maxSize, maxBackups)
+	}
+}
+
+// nopCloser keeps the standard streams open when the log is closed.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,183 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"load-balancer/backend"
+	"load-balancer/lb"
+	"load-balancer/requestid"
+	"load-balancer/serverpool"
+	"load-balancer/utils"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test a JSON record carries the request, response and upstream fields
+func TestMiddleware_JSON(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("created"))
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err, "failed to parse url")
+	sp, err := serverpool.NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+	sp.AddBackend(backend.NewBackend(u))
+
+	var buf bytes.Buffer
+	l, err := New(Options{Output: &buf})
+	require.NoError(t, err, "failed to create access logger")
+	h := l.Middleware(lb.NewLoadBalancer(sp))
+
+	req := httptest.NewRequest(http.MethodPost, "/orders?id=7", strings.NewReader("{}"))
+	req.RemoteAddr = "203.0.113.9:51234"
+	req.Header.Set("User-Agent", "curl/8.0")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	var e jsonEntry
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &e))
+	assert.Equal(t, rr.Header().Get(requestid.Header), e.RequestID)
+	assert.NotEmpty(t, e.RequestID)
+	assert.Equal(t, "203.0.113.9", e.ClientIP)
+	assert.Equal(t, http.MethodPost, e.Method)
+	assert.Equal(t, "/orders?id=7", e.Path)
+	assert.Equal(t, http.StatusCreated, e.Status)
+	assert.Equal(t, int64(len("created")), e.Bytes)
+	assert.Equal(t, s.URL, e.Backend)
+	assert.Equal(t, 0, e.Retries)
+	assert.Equal(t, "curl/8.0", e.UserAgent)
+	assert.Positive(t, e.Duration)
+	assert.Positive(t, e.UpstreamDuration)
+	assert.LessOrEqual(t, e.UpstreamDuration, e.Duration)
+	assert.Equal(t, 1, strings.Count(buf.String(), "\n"))
+}
+
+// Test a request without an available backend is logged with the load balancer response
+func TestMiddleware_NoBackend(t *testing.T) {
+	sp, err := serverpool.NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	var buf bytes.Buffer
+	l, err := New(Options{Output: &buf})
+	require.NoError(t, err, "failed to create access logger")
+
+	l.Middleware(lb.NewLoadBalancer(sp)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var e jsonEntry
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &e))
+	assert.Equal(t, http.StatusServiceUnavailable, e.Status)
+	assert.Empty(t, e.Backend)
+}
+
+// testEntry is a fixed entry for the text formats.
+var testEntry = Entry{
+	Time:      time.Date(2000, time.October, 10, 13, 55, 36, 0, time.FixedZone("", -7*3600)),
+	ClientIP:  "127.0.0.1",
+	Method:    http.MethodGet,
+	Path:      "/apache_pb.gif",
+	Proto:     "HTTP/1.0",
+	User:      "frank",
+	Status:    http.StatusOK,
+	Bytes:     2326,
+	Duration:  150 * time.Millisecond,
+	Backend:   "http://10.0.0.1:8081",
+	Retries:   1,
+	Referer:   "http://www.example.com/start.html",
+	UserAgent: `Mozilla/4.08 "quoted"`,
+}
+
+// Test the Common and Combined Log Formats
+func TestLog_CommonCombined(t *testing.T) {
+	for _, tc := range []struct {
+		format string
+		want   string
+	}{
+		{FormatCommon, `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326` + "\n"},
+		{FormatCombined, `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08 \"quoted\""` + "\n"},
+	} {
+		var buf bytes.Buffer
+		l, err := New(Options{Format: tc.format, Output: &buf})
+		require.NoError(t, err, tc.format)
+
+		l.Log(&testEntry)
+		assert.Equal(t, tc.want, buf.String(), tc.format)
+	}
+}
+
+// Test empty fields are logged as dashes and the request line cannot break the record
+func TestLog_CommonEscaping(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New(Options{Format: FormatCommon, Output: &buf})
+	require.NoError(t, err, "failed to create access logger")
+
+	e := testEntry
+	e.User, e.Bytes, e.Path = "", 0, "/a\"b\nc"
+	l.Log(&e)
+
+	assert.Equal(t, `127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /a\"b\nc HTTP/1.0" 200 -`+"\n", buf.String())
+}
+
+// Test the template format executes on the entry and ends records with a newline
+func TestLog_Template(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New(Options{
+		Format:   FormatTemplate,
+		Template: "{{.ClientIP}} {{.Status}} {{.Backend}} retries={{.Retries}} {{.Duration}}",
+		Output:   &buf,
+	})
+	require.NoError(t, err, "failed to create access logger")
+
+	l.Log(&testEntry)
+	assert.Equal(t, "127.0.0.1 200 http://10.0.0.1:8081 retries=1 150ms\n", buf.String())
+}
+
+// Test invalid options are rejected
+func TestNew_Invalid(t *testing.T) {
+	var buf bytes.Buffer
+
+	for _, opts := range []Options{
+		{Format: "xml", Output: &buf},
+		{Format: FormatTemplate, Output: &buf},
+		{Format: FormatTemplate, Template: "{{.Status", Output: &buf},
+		{Format: FormatJSON},
+	} {
+		_, err := New(opts)
+		assert.Error(t, err, opts.Format)
+	}
+}
+
+// Test the wrapper records the final status, not informational ones, and lets flushes through
+func TestResponseWriter(t *testing.T) {
+	w := &responseWriter{ResponseWriter: httptest.NewRecorder()}
+	w.WriteHeader(http.StatusEarlyHints)
+	assert.Zero(t, w.status)
+
+	rr := httptest.NewRecorder()
+	w = &responseWriter{ResponseWriter: rr}
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = w.Write([]byte("abc"))
+	require.NoError(t, http.NewResponseController(w).Flush())
+
+	assert.Equal(t, http.StatusAccepted, w.statusCode())
+	assert.Equal(t, int64(3), w.bytes)
+	assert.True(t, rr.Flushed)
+}
+
+// Test the client resolved by the load balancer is logged, the connection address otherwise
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+
+	assert.Equal(t, "10.0.0.1", clientIP(req, &lb.RequestInfo{}))
+	assert.Equal(t, "198.51.100.4", clientIP(req, &lb.RequestInfo{ClientIP: "198.51.100.4"}))
+}
@@ -0,0 +1,118 @@
+package accesslog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile is an append-only file rotated by size.
+// On rotation the file is renamed to path.1, path.1 to path.2 and so on, dropping files past maxBackups.
+type RotatingFile struct {
+	path       string
+	maxSize    int64 // bytes before rotation, 0 disables rotation
+	maxBackups int   // rotated files kept
+	mux        sync.Mutex
+	file       *os.File
+	size       int64
+}
+
+// NewRotatingFile opens the file at path for appending, creating it if needed.
+// The file is rotated before a write would make it exceed maxSize bytes, 0 disables rotation.
+func NewRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	if path == "" {
+		return nil, errors.New("access log path expected, none provided")
+	}
+
+	f := &RotatingFile{path: path, maxSize: maxSize, maxBackups: max(maxBackups, 0)}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write appends p to the file, rotating it first if p does not fit.
+// A single write larger than maxSize goes to a fresh file.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close closes the file.
+func (f *RotatingFile) Close() error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// open opens the file for appending and reads its current size.
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// rotate shifts the backups, moves the current file to path.1 and opens a new file.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	if f.maxBackups == 0 {
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return f.open()
+	}
+
+	// The oldest backup is overwritten by the rename below
+	for i := f.maxBackups - 1; i >= 1; i-- {
+		err := os.Rename(f.backup(i), f.backup(i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.Rename(f.path, f.backup(1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return f.open()
+}
+
+// backup returns the path of the i-th rotated file.
+func (f *RotatingFile) backup(i int) string {
+	return fmt.Sprintf("%s.%d", f.path, i)
+}
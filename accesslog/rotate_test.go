@@ -0,0 +1,82 @@
+package accesslog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test the file is rotated by size, keeping the newest backups
+func TestRotatingFile_Rotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+
+	f, err := NewRotatingFile(path, 10, 2)
+	require.NoError(t, err, "failed to open access log")
+	defer f.Close()
+
+	for _, line := range []string{"aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n", "dddddddd\n"} {
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err, "failed to write")
+	}
+
+	read := func(p string) string {
+		b, err := os.ReadFile(p)
+		require.NoError(t, err, "failed to read %s", p)
+		return string(b)
+	}
+	assert.Equal(t, "dddddddd\n", read(path))
+	assert.Equal(t, "cccccccc\n", read(path+".1"))
+	assert.Equal(t, "bbbbbbbb\n", read(path+".2"))
+	assert.NoFileExists(t, path+".3")
+}
+
+// Test an existing file is appended to and counts towards the size limit
+func TestRotatingFile_Append(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	require.NoError(t, os.WriteFile(path, []byte("old\n"), 0o644))
+
+	f, err := NewRotatingFile(path, 10, 1)
+	require.NoError(t, err, "failed to open access log")
+
+	_, err = f.Write([]byte("new\n"))
+	require.NoError(t, err, "failed to write")
+	_, err = f.Write([]byte("rotated\n"))
+	require.NoError(t, err, "failed to write")
+	require.NoError(t, f.Close())
+
+	b, err := os.ReadFile(path + ".1")
+	require.NoError(t, err, "failed to read backup")
+	assert.Equal(t, "old\nnew\n", string(b))
+
+	_, err = f.Write([]byte("closed\n"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
+
+// Test rotation without backups truncates the file, and a zero size never rotates
+func TestRotatingFile_NoBackupsNoLimit(t *testing.T) {
+	dir := t.TempDir()
+
+	f, err := NewRotatingFile(filepath.Join(dir, "a.log"), 4, 0)
+	require.NoError(t, err, "failed to open access log")
+	_, _ = f.Write([]byte("1234"))
+	_, _ = f.Write([]byte("5678"))
+	require.NoError(t, f.Close())
+
+	g, err := NewRotatingFile(filepath.Join(dir, "b.log"), 0, 3)
+	require.NoError(t, err, "failed to open access log")
+	_, _ = g.Write([]byte(strings.Repeat("x", 100)))
+	_, _ = g.Write([]byte(strings.Repeat("y", 100)))
+	require.NoError(t, g.Close())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err, "failed to list dir")
+	assert.Len(t, entries, 2)
+
+	b, err := os.ReadFile(filepath.Join(dir, "a.log"))
+	require.NoError(t, err, "failed to read log")
+	assert.Equal(t, "5678", string(b))
+}
@@ -0,0 +1,265 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"load-balancer/backend"
+	"load-balancer/serverpool"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Options configures the admin API.
+type Options struct {
+	Token        string        // bearer token required on every request
+	DrainTimeout time.Duration // drain timeout used when the request sets none (default 30s)
+
+	// NewBackend builds a backend added through the API, configured like the backends from the config file.
+	NewBackend func(u *url.URL, weight int) (backend.Backend, error)
+
+	// HealthCheck runs an immediate health check round of the pool.
+	HealthCheck func(ctx context.Context)
+
+	// Metrics serves the Prometheus scrape at GET /metrics, nil disables the endpoint.
+	Metrics http.Handler
+
+	Logger *zap.Logger // changes made through the API are logged here (default no-op)
+}
+
+// BackendStatus is the JSON representation of a backend.
+type BackendStatus struct {
+	URL               string `json:"url"`
+	Alive             bool   `json:"alive"`
+	Draining          bool   `json:"draining"`
+	Ejected           bool   `json:"ejected"`
+	Forced            string `json:"forced"`
+	Circuit           string `json:"circuit"`
+	ActiveConnections int    `json:"active_connections"`
+	Weight            int    `json:"weight"`
+}
+
+// addBackendRequest is the body of POST /backends.
+type addBackendRequest struct {
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// errorResponse is the body of every failed request.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// api serves the admin endpoints for a server pool.
+type api struct {
+	sp   serverpool.ServerPool
+	opts Options
+}
+
+// NewHandler returns the admin API for the server pool:
+//
+//	GET    /backends                          list backends
+//	POST   /backends                          add a backend, body {"url": ..., "weight": ...}
+//	DELETE /backends?url=                     remove a backend at once
+//	POST   /backends/drain?url=[&timeout=30s] drain a backend, then remove it
+//	POST   /backends/force?url=&state=        force a backend up or down, or release it with state=none
+//	POST   /healthcheck                       run a health check round and list backends
+//	GET    /metrics                           Prometheus metrics, if enabled
+//
+// Every request must carry the token as "Authorization: Bearer <token>".
+// Returns an error if the token is empty.
+func NewHandler(sp serverpool.ServerPool, opts Options) (http.Handler, error) {
+	if opts.Token == "" {
+		return nil, errors.New("admin token expected, none provided")
+	}
+	if opts.DrainTimeout <= 0 {
+		opts.DrainTimeout = 30 * time.Second
+	}
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop()
+	}
+
+	a := &api{sp: sp, opts: opts}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /backends", a.listBackends)
+	mux.HandleFunc("POST /backends", a.addBackend)
+	mux.HandleFunc("DELETE /backends", a.removeBackend)
+	mux.HandleFunc("POST /backends/drain", a.drainBackend)
+	mux.HandleFunc("POST /backends/force", a.forceBackend)
+	mux.HandleFunc("POST /healthcheck", a.healthCheck)
+	if opts.Metrics != nil {
+		mux.Handle("GET /metrics", opts.Metrics)
+	}
+
+	return a.authenticate(mux), nil
+}
+
+// authenticate rejects requests without the bearer token.
+func (a *api) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.opts.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeError(w, http.StatusUnauthorized, "invalid or missing token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *api) listBackends(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.statuses())
+}
+
+func (a *api) addBackend(w http.ResponseWriter, r *http.Request) {
+	var req addBackendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body: "+err.Error())
+		return
+	}
+
+	u, err := url.Parse(req.URL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		writeError(w, http.StatusBadRequest, "invalid backend url")
+		return
+	}
+	if a.find(u.String()) != nil {
+		writeError(w, http.StatusConflict, "backend already in the pool")
+		return
+	}
+	if a.opts.NewBackend == nil {
+		writeError(w, http.StatusNotImplemented, "adding backends is not supported")
+		return
+	}
+
+	b, err := a.opts.NewBackend(u, req.Weight)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	a.sp.AddBackend(b)
+
+	a.opts.Logger.Info("backend added", zap.String("url", u.String()), zap.Int("weight", b.GetWeight()))
+	writeJSON(w, http.StatusCreated, status(b))
+}
+
+func (a *api) removeBackend(w http.ResponseWriter, r *http.Request) {
+	u := r.URL.Query().Get("url")
+	if !a.sp.RemoveBackend(u) {
+		writeError(w, http.StatusNotFound, "backend not found")
+		return
+	}
+
+	a.opts.Logger.Info("backend removed", zap.String("url", u))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *api) drainBackend(w http.ResponseWriter, r *http.Request) {
+	u := r.URL.Query().Get("url")
+
+	timeout := a.opts.DrainTimeout
+	if v := r.URL.Query().Get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			writeError(w, http.StatusBadRequest, "invalid timeout")
+			return
+		}
+		timeout = d
+	}
+
+	b := a.find(u)
+	if b == nil || !a.sp.DrainBackend(u, timeout) {
+		writeError(w, http.StatusNotFound, "backend not found")
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, status(b))
+}
+
+func (a *api) forceBackend(w http.ResponseWriter, r *http.Request) {
+	u := r.URL.Query().Get("url")
+
+	var state backend.ForcedState
+	switch r.URL.Query().Get("state") {
+	case "up":
+		state = backend.ForcedUp
+	case "down":
+		state = backend.ForcedDown
+	case "none":
+		state = backend.NotForced
+	default:
+		writeError(w, http.StatusBadRequest, `state must be "up", "down" or "none"`)
+		return
+	}
+
+	b := a.find(u)
+	if b == nil {
+		writeError(w, http.StatusNotFound, "backend not found")
+		return
+	}
+	b.SetForcedState(state)
+
+	a.opts.Logger.Info("backend state forced", zap.String("url", u), zap.String("state", state.String()))
+	writeJSON(w, http.StatusOK, status(b))
+}
+
+func (a *api) healthCheck(w http.ResponseWriter, r *http.Request) {
+	if a.opts.HealthCheck == nil {
+		writeError(w, http.StatusNotImplemented, "health checks are not supported")
+		return
+	}
+
+	a.opts.HealthCheck(r.Context())
+	writeJSON(w, http.StatusOK, a.statuses())
+}
+
+// find returns the backend with the given url, nil if it is not in the pool.
+func (a *api) find(u string) backend.Backend {
+	for _, b := range a.sp.GetBackends() {
+		if b.GetURL().String() == u {
+			return b
+		}
+	}
+	return nil
+}
+
+// statuses returns the status of every backend in the pool.
+func (a *api) statuses() []BackendStatus {
+	backends := a.sp.GetBackends()
+
+	statuses := make([]BackendStatus, 0, len(backends))
+	for _, b := range backends {
+		statuses = append(statuses, status(b))
+	}
+	return statuses
+}
+
+// status returns the JSON representation of the backend.
+func status(b backend.Backend) BackendStatus {
+	return BackendStatus{
+		URL:               b.GetURL().String(),
+		Alive:             b.IsAlive(),
+		Draining:          b.IsDraining(),
+		Ejected:           b.IsEjected(),
+		Forced:            b.GetForcedState().String(),
+		Circuit:           b.GetCircuitState().String(),
+		ActiveConnections: b.GetActiveConnections(),
+		Weight:            b.GetWeight(),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, errorResponse{Error: msg})
+}
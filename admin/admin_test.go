@@ -0,0 +1,189 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"load-balancer/backend"
+	"load-balancer/serverpool"
+	"load-balancer/utils"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testToken = "secret"
+
+// newTestAPI creates an admin API over a round-robin pool with two backends.
+func newTestAPI(t *testing.T, opts Options) (http.Handler, serverpool.ServerPool) {
+	t.Helper()
+
+	sp, err := serverpool.NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	for _, raw := range []string{"http://127.0.0.1:8081", "http://127.0.0.1:8082"} {
+		u, err := url.Parse(raw)
+		require.NoError(t, err, "failed to parse url")
+		sp.AddBackend(backend.NewBackend(u))
+	}
+
+	opts.Token = testToken
+	if opts.NewBackend == nil {
+		opts.NewBackend = func(u *url.URL, weight int) (backend.Backend, error) {
+			return backend.NewBackendWithOptions(u, backend.Options{Weight: weight}), nil
+		}
+	}
+
+	h, err := NewHandler(sp, opts)
+	require.NoError(t, err, "failed to create admin handler")
+	return h, sp
+}
+
+// do sends an authenticated request to the admin API.
+func do(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+// Test requests without the right token are rejected
+func TestAdmin_Unauthorized(t *testing.T) {
+	h, _ := newTestAPI(t, Options{})
+
+	for _, auth := range []string{"", "Bearer wrong", "Basic " + testToken, testToken} {
+		req := httptest.NewRequest(http.MethodGet, "/backends", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code, auth)
+	}
+
+	_, err := NewHandler(nil, Options{})
+	assert.Error(t, err, "empty token must be refused")
+}
+
+// Test listing backends with their state
+func TestAdmin_ListBackends(t *testing.T) {
+	h, sp := newTestAPI(t, Options{})
+	sp.GetBackends()[1].SetAlive(false)
+
+	rr := do(h, http.MethodGet, "/backends", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+	var statuses []BackendStatus
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &statuses))
+	assert.Equal(t, []BackendStatus{
+		{URL: "http://127.0.0.1:8081", Alive: true, Forced: "none", Circuit: "closed", Weight: 1},
+		{URL: "http://127.0.0.1:8082", Alive: false, Forced: "none", Circuit: "closed", Weight: 1},
+	}, statuses)
+}
+
+// Test adding and removing backends
+func TestAdmin_AddRemoveBackend(t *testing.T) {
+	h, sp := newTestAPI(t, Options{})
+
+	rr := do(h, http.MethodPost, "/backends", `{"url": "http://127.0.0.1:8083", "weight": 3}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	assert.Equal(t, 3, sp.GetServerPoolSize())
+	assert.Equal(t, 3, sp.GetBackends()[2].GetWeight())
+
+	assert.Equal(t, http.StatusConflict, do(h, http.MethodPost, "/backends", `{"url": "http://127.0.0.1:8083"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(h, http.MethodPost, "/backends", `{"url": "127.0.0.1"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(h, http.MethodPost, "/backends", `not json`).Code)
+
+	rr = do(h, http.MethodDelete, "/backends?url="+url.QueryEscape("http://127.0.0.1:8081"), "")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Equal(t, 2, sp.GetServerPoolSize())
+
+	assert.Equal(t, http.StatusNotFound, do(h, http.MethodDelete, "/backends?url=http://127.0.0.1:8081", "").Code)
+}
+
+// Test draining a backend stops selecting it and removes it once idle
+func TestAdmin_DrainBackend(t *testing.T) {
+	h, sp := newTestAPI(t, Options{})
+	drained := sp.GetBackends()[0]
+
+	rr := do(h, http.MethodPost, "/backends/drain?url=http://127.0.0.1:8081&timeout=1s", "")
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+	assert.True(t, drained.IsDraining())
+
+	assert.Eventually(t, func() bool { return sp.GetServerPoolSize() == 1 }, time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, http.StatusBadRequest, do(h, http.MethodPost, "/backends/drain?url=http://127.0.0.1:8082&timeout=soon", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(h, http.MethodPost, "/backends/drain?url=http://127.0.0.1:9999", "").Code)
+}
+
+// Test forcing a backend down overrides health checks until released
+func TestAdmin_ForceBackend(t *testing.T) {
+	h, sp := newTestAPI(t, Options{})
+	b := sp.GetBackends()[0]
+
+	rr := do(h, http.MethodPost, "/backends/force?url=http://127.0.0.1:8081&state=down", "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.False(t, b.IsAlive())
+	assert.False(t, b.ReportHealthCheck(true).Alive, "health checks are overridden")
+
+	var status BackendStatus
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &status))
+	assert.Equal(t, "down", status.Forced)
+
+	do(h, http.MethodPost, "/backends/force?url=http://127.0.0.1:8081&state=up", "")
+	assert.True(t, b.IsAlive())
+
+	do(h, http.MethodPost, "/backends/force?url=http://127.0.0.1:8081&state=none", "")
+	assert.Equal(t, backend.NotForced, b.GetForcedState())
+
+	assert.Equal(t, http.StatusBadRequest, do(h, http.MethodPost, "/backends/force?url=http://127.0.0.1:8081&state=sideways", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(h, http.MethodPost, "/backends/force?url=http://127.0.0.1:9999&state=up", "").Code)
+}
+
+// Test triggering a health check round
+func TestAdmin_HealthCheck(t *testing.T) {
+	rounds := 0
+	h, sp := newTestAPI(t, Options{HealthCheck: func(ctx context.Context) { rounds++ }})
+
+	rr := do(h, http.MethodPost, "/healthcheck", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 1, rounds)
+
+	var statuses []BackendStatus
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &statuses))
+	assert.Len(t, statuses, sp.GetServerPoolSize())
+}
+
+// Test the metrics handler is served behind the token, and only when set
+func TestAdmin_Metrics(t *testing.T) {
+	h, _ := newTestAPI(t, Options{})
+	assert.Equal(t, http.StatusNotFound, do(h, http.MethodGet, "/metrics", "").Code)
+
+	h, _ = newTestAPI(t, Options{Metrics: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("lb_up 1\n"))
+	})})
+
+	rr := do(h, http.MethodGet, "/metrics", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "lb_up 1\n", rr.Body.String())
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+// Test unsupported methods are rejected
+func TestAdmin_MethodNotAllowed(t *testing.T) {
+	h, _ := newTestAPI(t, Options{})
+
+	assert.Equal(t, http.StatusMethodNotAllowed, do(h, http.MethodGet, "/healthcheck", "").Code)
+}
@@ -2,7 +2,9 @@ package backend
 
 import (
 	"context"
-	"load-balancer/utils"
+	"errors"
+	"load-balancer/tracing"
+	"math"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -17,22 +19,51 @@ type Backend interface {
 	IsAlive() bool // set backend status
 	GetURL() *url.URL
 	GetActiveConnections() int
+	SetWeight(int) // alter backend weight
+	GetWeight() int
+	GetLatency() time.Duration  // peak-EWMA response time
+	SetEjected(until time.Time) // exclude backend from selection until the given time
+	IsEjected() bool
+	SetDraining(bool) // stop sending new requests, in-flight requests continue
+	IsDraining() bool
+	GetCircuitState() CircuitState
+	IsCircuitOpen() bool         // true while the circuit breaker rejects requests
+	GetSlowStartFactor() float64 // share of normal traffic while ramping up after recovery
+	GetHealthChecker() HealthChecker
+	ReportHealthCheck(healthy bool) HealthTransition // apply an active health check result
+	SetForcedState(ForcedState)                      // override active health checks
+	GetForcedState() ForcedState
 	http.Handler // allows backend to serve HTTP requests
 }
 
 // backend represents a single backend server.
 type backend struct {
 	url          *url.URL
-	alive        bool                   // backend status
-	mux          sync.RWMutex           // protect concurrent access (avoid race conditions)
-	connections  int                    // number of active connections to the backend
+	alive        bool                     // backend status
+	mux          sync.RWMutex             // protect concurrent access (avoid race conditions)
+	connections  int                      // number of active connections to the backend
+	weight       int                      // relative share of traffic for weighted strategies
+	latency      float64                  // peak-EWMA response time in nanoseconds
+	lastSample   time.Time                // time of the last latency sample
+	ejectedUntil time.Time                // outlier ejection end, independent of alive
+	draining     bool                     // no new requests, set before removal from the pool
+	slowStart    time.Duration            // ramp-up period after recovery, zero disables slow start
+	recoveredAt  time.Time                // last time the backend was marked alive again
+	retryPolicy  RetryPolicy              // responses treated as failures
+	observer     func(*http.Request, int) // called with the request and status of every upstream response
+	attempts     func(Attempt)            // called with the outcome of every request attempt
+	tracer       *tracing.Tracer          // records a span per attempt, nil disables tracing
+	breaker      *circuitBreaker          // nil when the circuit breaker is disabled
+	errorHandler func(http.ResponseWriter, *http.Request, error)
+	healthCheck  HealthChecker          // active health check, nil means the default HTTP check
+	health       healthState            // active health check results
 	reverseProxy *httputil.ReverseProxy // rewrites and forwards request to the backend server
 }
 
 // SetAlive serves backend status.
 func (b *backend) SetAlive(alive bool) {
 	b.mux.Lock()
-	b.alive = alive
+	b.setAlive(alive)
 	b.mux.Unlock()
 }
 
@@ -55,9 +86,147 @@ func (b *backend) GetActiveConnections() int {
 	return connections
 }
 
+// SetWeight sets the backend weight. Weights below 1 are treated as 1.
+func (b *backend) SetWeight(weight int) {
+	if weight < 1 {
+		weight = 1
+	}
+	b.mux.Lock()
+	b.weight = weight
+	b.mux.Unlock()
+}
+
+// GetWeight returns the backend weight.
+func (b *backend) GetWeight() int {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.weight
+}
+
+// SetEjected excludes the backend from selection until the given time.
+// A zero time lifts the ejection.
+func (b *backend) SetEjected(until time.Time) {
+	b.mux.Lock()
+	b.ejectedUntil = until
+	b.mux.Unlock()
+}
+
+// IsEjected checks if the backend is currently ejected.
+func (b *backend) IsEjected() bool {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return time.Now().Before(b.ejectedUntil)
+}
+
+// SetDraining marks the backend as draining: it keeps its in-flight requests but gets no new ones.
+func (b *backend) SetDraining(draining bool) {
+	b.mux.Lock()
+	b.draining = draining
+	b.mux.Unlock()
+}
+
+// IsDraining checks if the backend is draining.
+func (b *backend) IsDraining() bool {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.draining
+}
+
+// SetCircuitBreaker wraps the backend with a circuit breaker.
+// Replacing the breaker of a running backend starts over with a closed circuit.
+func (b *backend) SetCircuitBreaker(opts BreakerOptions) {
+	breaker := newCircuitBreaker(b.url.String(), opts)
+	b.mux.Lock()
+	b.breaker = breaker
+	b.mux.Unlock()
+}
+
+// DisableCircuitBreaker removes the circuit breaker of the backend.
+func (b *backend) DisableCircuitBreaker() {
+	b.mux.Lock()
+	b.breaker = nil
+	b.mux.Unlock()
+}
+
+// getBreaker returns the circuit breaker of the backend, nil when it is disabled.
+func (b *backend) getBreaker() *circuitBreaker {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.breaker
+}
+
+// GetCircuitState returns the circuit breaker state (always closed without a breaker).
+func (b *backend) GetCircuitState() CircuitState {
+	breaker := b.getBreaker()
+	if breaker == nil {
+		return CircuitClosed
+	}
+	return breaker.getState()
+}
+
+// IsCircuitOpen checks if the circuit breaker currently rejects requests.
+func (b *backend) IsCircuitOpen() bool {
+	breaker := b.getBreaker()
+	return breaker != nil && breaker.isOpen()
+}
+
+// latencyDecay is the time constant of the latency moving average.
+// Older samples lose about two thirds of their influence every latencyDecay.
+const latencyDecay = 10 * time.Second
+
+// GetLatency returns the peak-EWMA response time of the backend (zero until the first response).
+// The average decays towards zero while no sample comes in, so a backend left alone after a slow
+// spike is tried again and can prove it recovered.
+func (b *backend) GetLatency() time.Duration {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return time.Duration(b.latency * latencyWeight(time.Since(b.lastSample)))
+}
+
+// latencyWeight returns the share of its influence a latency average keeps after the given time.
+func latencyWeight(elapsed time.Duration) float64 {
+	return math.Exp(-float64(elapsed) / float64(latencyDecay))
+}
+
+// observeLatency adds a response time sample to the peak-EWMA.
+// A sample above the average replaces it immediately, so a backend that becomes slow is penalized at once,
+// while lower samples are blended in with a weight depending on the time since the previous sample.
+func (b *backend) observeLatency(d time.Duration) {
+	now := time.Now()
+	sample := float64(d)
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if sample > b.latency {
+		b.latency = sample
+	} else {
+		w := latencyWeight(now.Sub(b.lastSample))
+		b.latency = b.latency*w + sample*(1-w)
+	}
+	b.lastSample = now
+}
+
 // ServehTTP forwards incoming client request to the backend's reverse proxy.
 // reverseProxy.ServeHTTP rewrites the request to match the destination backend server.
 func (b *backend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	if b.tracer != nil {
+		var span *tracing.Span
+		r, span = b.startAttemptSpan(r)
+		defer span.End()
+	}
+
+	if breaker := b.getBreaker(); breaker != nil && !breaker.allow() {
+		b.observeAttempt(r, 0, ErrCircuitOpen, start)
+		b.errorHandler(w, r, ErrCircuitOpen)
+		return
+	}
+
+	// Carry the start of the attempt to modifyResponse and handleError
+	r = r.WithContext(context.WithValue(r.Context(), attemptStartKey{}, start))
+
 	// Increment
 	b.mux.Lock()
 	b.connections++
@@ -70,51 +239,184 @@ func (b *backend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		b.mux.Unlock()
 	}()
 
+	r, cancel := b.getRetryPolicy().withPerTryTimeout(r)
+	defer cancel()
+
 	b.reverseProxy.ServeHTTP(w, r)
 }
 
-// CheckBackendHealth sends a GET request to the backend to determine if it is reachable.
-// Returns true if status is 200 and false otherwise.
-func CheckBackendHealth(ctx context.Context, b Backend) bool {
-	config, err := utils.GetLBConfig()
-	if err != nil {
-		return false
+// SetHealthChecker sets the active health check of the backend.
+func (b *backend) SetHealthChecker(hc HealthChecker) {
+	b.mux.Lock()
+	b.healthCheck = hc
+	b.mux.Unlock()
+}
+
+// GetHealthChecker returns the active health check of the backend, the default HTTP check if none is set.
+func (b *backend) GetHealthChecker() HealthChecker {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	if b.healthCheck == nil {
+		return HealthCheck{}
 	}
+	return b.healthCheck
+}
 
-	reqCtx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(config.BackendTimeout))
-	defer cancel()
+// CheckBackendHealth runs the health checker of the backend to determine if it is healthy.
+// The caller bounds the check through the context deadline.
+// Returns nil if the backend is healthy, and the reason otherwise.
+func CheckBackendHealth(ctx context.Context, b Backend) error {
+	return b.GetHealthChecker().Check(ctx, b)
+}
 
-	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, b.GetURL().String(), nil) // removed +"/health"
-	if err != nil {
-		return false
+func (b *backend) SetErrorHandler(h func(http.ResponseWriter, *http.Request, error)) {
+	b.errorHandler = h
+}
+
+// handleError records the failure in the circuit breaker and the latency average, and hands the error to the error handler.
+// Requests the client gave up on are not held against the backend.
+func (b *backend) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(context.Cause(r.Context()), ErrPerTryTimeout) {
+		err = ErrPerTryTimeout
 	}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return false
+	// Retryable statuses were already recorded by modifyResponse
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		breaker := b.getBreaker()
+		if ClientGone(r) {
+			// No result, but a half-open probe must not stay taken
+			if breaker != nil {
+				breaker.release()
+			}
+		} else {
+			b.observeLatency(time.Since(attemptStart(r)))
+			if breaker != nil {
+				breaker.record(r.Context(), false)
+			}
+		}
+		b.observeAttempt(r, 0, err, attemptStart(r))
 	}
-	defer resp.Body.Close()
 
-	return resp.StatusCode == http.StatusOK
+	b.errorHandler(w, r, err)
 }
 
-func (b *backend) SetErrorHandler(h func(http.ResponseWriter, *http.Request, error)) {
-	b.reverseProxy.ErrorHandler = h
+// SetResponseObserver registers a function called with the request and status code of every upstream response.
+// Transport errors never reach the observer, they go to the error handler.
+func (b *backend) SetResponseObserver(fn func(r *http.Request, statusCode int)) {
+	b.observer = fn
 }
 
-// NewBackend creates a new backend with the provided URL and initializes its reverse proxy.
-func NewBackend(u *url.URL) *backend {
-	proxy := httputil.NewSingleHostReverseProxy(u)
+// modifyResponse reports the upstream response to the observer and rejects retryable responses.
+// The latency sample ends here, before the body is copied and before any retry runs.
+func (b *backend) modifyResponse(resp *http.Response) error {
+	echoRequestID(resp)
+	b.observeLatency(time.Since(attemptStart(resp.Request)))
+
+	if b.observer != nil {
+		b.observer(resp.Request, resp.StatusCode)
+	}
+	if breaker := b.getBreaker(); breaker != nil {
+		breaker.record(resp.Request.Context(), resp.StatusCode < 500)
+	}
+
+	if !b.getRetryPolicy().retryable(resp) {
+		b.observeAttempt(resp.Request, resp.StatusCode, nil, attemptStart(resp.Request))
+		return nil
+	}
+	resp.Body.Close()
+
+	err := &StatusError{StatusCode: resp.StatusCode}
+	b.observeAttempt(resp.Request, resp.StatusCode, err, attemptStart(resp.Request))
+	return err
+}
+
+// Attempt is the outcome of a single try of a request at a backend.
+type Attempt struct {
+	Request    *http.Request
+	StatusCode int           // upstream status code, 0 if the backend did not respond
+	Err        error         // transport error, retryable status or open circuit, nil on success
+	Duration   time.Duration // time until the response headers or the failure
+}
+
+// attemptStartKey is the context key of the start time of an attempt.
+type attemptStartKey struct{}
+
+// attemptStart returns the start time of the attempt carrying the request.
+func attemptStart(r *http.Request) time.Time {
+	start, _ := r.Context().Value(attemptStartKey{}).(time.Time)
+	return start
+}
+
+// SetAttemptObserver registers a function called with the outcome of every request attempt,
+// including attempts rejected by the circuit breaker and attempts failing with a retryable status.
+func (b *backend) SetAttemptObserver(fn func(Attempt)) {
+	b.attempts = fn
+}
 
-	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-		http.Error(w, "proxy error: "+err.Error(), http.StatusBadGateway)
+// observeAttempt reports the outcome of an attempt to the attempt observer and the attempt span.
+func (b *backend) observeAttempt(r *http.Request, statusCode int, err error, start time.Time) {
+	if b.tracer != nil {
+		endAttemptSpan(r, statusCode, err)
 	}
+	if b.attempts == nil {
+		return
+	}
+	b.attempts(Attempt{Request: r, StatusCode: statusCode, Err: err, Duration: time.Since(start)})
+}
+
+// NewBackend creates a new backend with the provided URL and default options.
+func NewBackend(u *url.URL) *backend {
+	return NewBackendWithOptions(u, Options{})
+}
+
+// Options configures a backend created with NewBackendWithOptions.
+type Options struct {
+	Weight           int              // relative share of traffic for weighted strategies (default 1)
+	HealthCheck      HealthChecker    // active health check (default GET / expecting a 200)
+	HealthThresholds HealthThresholds // rise/fall thresholds and flap damping of the active health check
+	RetryPolicy      RetryPolicy      // responses treated as failures
+	CircuitBreaker   *BreakerOptions  // nil disables the circuit breaker
+	SlowStart        time.Duration    // ramp-up period of a recovered backend, zero disables slow start
+	Tracer           *tracing.Tracer  // records a span per attempt, nil disables tracing
+}
 
-	return &backend{
+// NewBackendWithOptions creates an alive backend with the provided URL and initializes its reverse proxy.
+func NewBackendWithOptions(u *url.URL, opts Options) *backend {
+	proxy := &httputil.ReverseProxy{}
+
+	b := &backend{
 		url:          u,
 		alive:        true,
 		mux:          sync.RWMutex{},
 		connections:  0,
+		weight:       1,
+		slowStart:    opts.SlowStart,
+		healthCheck:  opts.HealthCheck,
+		health:       healthState{thresholds: opts.HealthThresholds},
 		reverseProxy: proxy,
+		errorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, "proxy error: "+err.Error(), http.StatusBadGateway)
+		},
+	}
+	proxy.ModifyResponse = b.modifyResponse
+	proxy.ErrorHandler = b.handleError
+
+	// Rewrite, unlike Director, drops the forwarding headers sent by the client before they can reach the backend
+	proxy.Rewrite = func(pr *httputil.ProxyRequest) {
+		pr.SetURL(u)
+		pr.Out.Host = pr.In.Host // keep the Host the client asked for
+		setForwardedHeaders(pr)
+		forwardRequestID(pr.Out)
+		b.injectTraceparent(pr.Out)
 	}
+
+	b.SetWeight(opts.Weight)
+	b.SetRetryPolicy(opts.RetryPolicy)
+	b.SetTracer(opts.Tracer)
+	if opts.CircuitBreaker != nil {
+		b.SetCircuitBreaker(*opts.CircuitBreaker)
+	}
+
+	return b
 }
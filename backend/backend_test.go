@@ -48,6 +48,124 @@ func TestBackendSetAlive(t *testing.T) {
 	assert.True(t, b.IsAlive())
 }
 
+// TestBackendWeight verifies the default weight and that SetWeight clamps to at least 1.
+func TestBackendWeight(t *testing.T) {
+	u, err := url.Parse("http://127.0.0.1:8080")
+	require.NoError(t, err, "failed to parse url")
+
+	b := NewBackend(u)
+	assert.Equal(t, 1, b.GetWeight())
+
+	b.SetWeight(5)
+	assert.Equal(t, 5, b.GetWeight())
+
+	b.SetWeight(0)
+	assert.Equal(t, 1, b.GetWeight())
+}
+
+// TestNewBackendWithOptions verifies the options are applied to the new backend.
+func TestNewBackendWithOptions(t *testing.T) {
+	u, err := url.Parse("http://127.0.0.1:8080")
+	require.NoError(t, err, "failed to parse url")
+
+	b := NewBackendWithOptions(u, Options{
+		Weight:           3,
+		HealthCheck:      TCPHealthCheck{},
+		HealthThresholds: HealthThresholds{Unhealthy: 2},
+		CircuitBreaker:   &BreakerOptions{MinimumRequests: 1},
+	})
+
+	assert.True(t, b.IsAlive())
+	assert.Equal(t, 3, b.GetWeight())
+	assert.Equal(t, TCPHealthCheck{}, b.GetHealthChecker())
+	assert.NotNil(t, b.breaker)
+
+	b.ReportHealthCheck(false)
+	assert.True(t, b.IsAlive(), "unhealthy threshold not reached")
+	b.ReportHealthCheck(false)
+	assert.False(t, b.IsAlive())
+}
+
+// TestBackendDraining verifies the draining flag is independent of the alive status.
+func TestBackendDraining(t *testing.T) {
+	u, err := url.Parse("http://127.0.0.1:8080")
+	require.NoError(t, err, "failed to parse url")
+
+	b := NewBackend(u)
+	assert.False(t, b.IsDraining())
+
+	b.SetDraining(true)
+	assert.True(t, b.IsDraining())
+	assert.True(t, b.IsAlive())
+
+	b.SetDraining(false)
+	assert.False(t, b.IsDraining())
+}
+
+// TestBackendEjected verifies that ejection expires on its own and is independent of the alive status.
+func TestBackendEjected(t *testing.T) {
+	u, err := url.Parse("http://127.0.0.1:8080")
+	require.NoError(t, err, "failed to parse url")
+
+	b := NewBackend(u)
+	assert.False(t, b.IsEjected())
+
+	b.SetEjected(time.Now().Add(50 * time.Millisecond))
+	assert.True(t, b.IsEjected())
+	assert.True(t, b.IsAlive())
+
+	time.Sleep(60 * time.Millisecond)
+	assert.False(t, b.IsEjected())
+}
+
+// TestBackendResponseObserver verifies that the observer receives upstream status codes.
+func TestBackendResponseObserver(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+
+	var statuses []int
+	b.SetResponseObserver(func(r *http.Request, statusCode int) { statuses = append(statuses, statusCode) })
+
+	b.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, []int{http.StatusInternalServerError}, statuses)
+}
+
+// TestBackendAttemptObserver verifies that the observer receives the outcome of successful and failed attempts.
+func TestBackendAttemptObserver(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+
+	var attempts []Attempt
+	b.SetAttemptObserver(func(a Attempt) { attempts = append(attempts, a) })
+
+	b.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+	require.Len(t, attempts, 1)
+	assert.Equal(t, http.StatusCreated, attempts[0].StatusCode)
+	assert.Equal(t, http.MethodPost, attempts[0].Request.Method)
+	assert.NoError(t, attempts[0].Err)
+	assert.Positive(t, attempts[0].Duration)
+
+	// A closed backend fails without a response
+	s.Close()
+	b.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Len(t, attempts, 2)
+	assert.Equal(t, 0, attempts[1].StatusCode)
+	assert.Error(t, attempts[1].Err)
+}
+
 // TestBackendReverseProxyInitialization verifies that the reverse proxy is initialized.
 func TestBackendReverseProxyInitialization(t *testing.T) {
 	u, err := url.Parse("http://127.0.0.1:8080")
@@ -102,6 +220,54 @@ func TestBackend_ProxyErrorHandling(t *testing.T) {
 	assert.Contains(t, string(body), "proxy error")
 }
 
+// TestBackend_Latency verifies the peak-EWMA follows slow responses immediately and decays on fast ones.
+func TestBackend_Latency(t *testing.T) {
+	u, err := url.Parse("http://127.0.0.1:8080")
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+
+	assert.Equal(t, time.Duration(0), b.GetLatency())
+
+	b.observeLatency(10 * time.Millisecond)
+	assert.InDelta(t, 10*time.Millisecond, b.GetLatency(), float64(time.Millisecond))
+
+	// Peak is taken immediately, the average only decays slowly on read
+	b.observeLatency(100 * time.Millisecond)
+	assert.InDelta(t, 100*time.Millisecond, b.GetLatency(), float64(time.Millisecond))
+
+	// Lower samples are blended in
+	b.lastSample = time.Now().Add(-latencyDecay)
+	b.observeLatency(10 * time.Millisecond)
+	assert.Less(t, b.GetLatency(), 100*time.Millisecond)
+	assert.Greater(t, b.GetLatency(), 10*time.Millisecond)
+
+	// Without samples the average decays on read
+	b.observeLatency(time.Second)
+	b.lastSample = time.Now().Add(-5 * latencyDecay)
+	assert.Less(t, b.GetLatency(), 10*time.Millisecond)
+}
+
+// TestBackend_LatencyExcludesRetries verifies the latency sample ends at the failure,
+// not after the retries run by the error handler.
+func TestBackend_LatencyExcludesRetries(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+	b.SetRetryPolicy(RetryPolicy{Statuses: []int{http.StatusServiceUnavailable}})
+	b.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, e error) {
+		time.Sleep(300 * time.Millisecond) // retry on another backend
+	})
+
+	b.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Greater(t, b.GetLatency(), time.Duration(0))
+	assert.Less(t, b.GetLatency(), 200*time.Millisecond)
+}
+
 func TestBackend_ActiveConnections(t *testing.T) {
 	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		time.Sleep(100 * time.Millisecond)
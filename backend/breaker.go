@@ -0,0 +1,216 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"load-balancer/requestid"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrCircuitOpen is passed to the error handler when the circuit breaker rejects a request.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of a backend circuit breaker.
+type CircuitState int
+
+const (
+	CircuitClosed   CircuitState = iota // requests flow, failures are counted
+	CircuitOpen                         // requests are rejected until the open timeout passes
+	CircuitHalfOpen                     // a limited number of probe requests decide whether to close again
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerOptions configures a circuit breaker.
+type BreakerOptions struct {
+	FailureRateThreshold float64       // failure ratio in the window that opens the circuit (default 0.5)
+	MinimumRequests      int           // requests needed in the window before the rate is evaluated (default 10)
+	Window               time.Duration // length of the counting window (default 10s)
+	OpenTimeout          time.Duration // time spent open before probing (default 30s)
+	HalfOpenProbes       int           // probe requests allowed, and successes needed to close, in half-open (default 3)
+	Logger               *zap.Logger   // state changes are logged here (default no-op)
+}
+
+// circuitBreaker tracks live traffic results of a backend.
+// It is independent of the alive flag, so active health checks cannot re-close a circuit that traffic keeps tripping.
+type circuitBreaker struct {
+	url         string
+	opts        BreakerOptions
+	mux         sync.Mutex
+	state       CircuitState
+	windowStart time.Time
+	requests    int // requests finished in the current window
+	failures    int // failed requests in the current window
+	openedAt    time.Time
+	probes      int // probe requests started in half-open
+	successes   int // successful probe requests in half-open
+}
+
+// newCircuitBreaker creates a closed circuit breaker, applying defaults to unset options.
+func newCircuitBreaker(url string, opts BreakerOptions) *circuitBreaker {
+	if opts.FailureRateThreshold <= 0 || opts.FailureRateThreshold > 1 {
+		opts.FailureRateThreshold = 0.5
+	}
+	if opts.MinimumRequests <= 0 {
+		opts.MinimumRequests = 10
+	}
+	if opts.Window <= 0 {
+		opts.Window = 10 * time.Second
+	}
+	if opts.OpenTimeout <= 0 {
+		opts.OpenTimeout = 30 * time.Second
+	}
+	if opts.HalfOpenProbes <= 0 {
+		opts.HalfOpenProbes = 3
+	}
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop()
+	}
+
+	return &circuitBreaker{
+		url:         url,
+		opts:        opts,
+		state:       CircuitClosed,
+		windowStart: time.Now(),
+	}
+}
+
+// setState changes the breaker state and logs the transition with the request id of the context.
+// Caller must hold cb.mux.
+func (cb *circuitBreaker) setState(ctx context.Context, state CircuitState, reason string) {
+	if cb.state == state {
+		return
+	}
+
+	requestid.Logger(ctx, cb.opts.Logger).Info(
+		"circuit breaker state change",
+		zap.String("url", cb.url),
+		zap.String("from", cb.state.String()),
+		zap.String("to", state.String()),
+		zap.String("reason", reason),
+	)
+
+	cb.state = state
+	now := time.Now()
+	switch state {
+	case CircuitOpen:
+		cb.openedAt = now
+	case CircuitHalfOpen:
+		cb.probes = 0
+		cb.successes = 0
+	case CircuitClosed:
+		cb.windowStart = now
+		cb.requests = 0
+		cb.failures = 0
+	}
+}
+
+// refresh moves an open circuit to half-open once the open timeout passed. Caller must hold cb.mux.
+func (cb *circuitBreaker) refresh() {
+	if cb.state == CircuitOpen && time.Since(cb.openedAt) >= cb.opts.OpenTimeout {
+		cb.setState(context.Background(), CircuitHalfOpen, "open timeout elapsed")
+	}
+}
+
+// getState returns the current breaker state.
+func (cb *circuitBreaker) getState() CircuitState {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	cb.refresh()
+	return cb.state
+}
+
+// isOpen reports whether the breaker would reject a request, without using up a probe.
+func (cb *circuitBreaker) isOpen() bool {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	cb.refresh()
+
+	switch cb.state {
+	case CircuitOpen:
+		return true
+	case CircuitHalfOpen:
+		return cb.probes >= cb.opts.HalfOpenProbes
+	default:
+		return false
+	}
+}
+
+// allow reports whether a request may be sent, using up a probe in half-open state.
+func (cb *circuitBreaker) allow() bool {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	cb.refresh()
+
+	switch cb.state {
+	case CircuitOpen:
+		return false
+	case CircuitHalfOpen:
+		if cb.probes >= cb.opts.HalfOpenProbes {
+			return false
+		}
+		cb.probes++
+		return true
+	default:
+		return true
+	}
+}
+
+// release gives back the probe taken by a request that ended without a result, such as one
+// the client gave up on, so the half-open circuit is not left waiting for it.
+func (cb *circuitBreaker) release() {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+
+	if cb.state == CircuitHalfOpen && cb.probes > 0 {
+		cb.probes--
+	}
+}
+
+// record adds the result of a request allowed by the breaker, ctx is the context of the request.
+func (cb *circuitBreaker) record(ctx context.Context, success bool) {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+
+	switch cb.state {
+	case CircuitHalfOpen:
+		if !success {
+			cb.setState(ctx, CircuitOpen, "probe request failed")
+			return
+		}
+		cb.successes++
+		if cb.successes >= cb.opts.HalfOpenProbes {
+			cb.setState(ctx, CircuitClosed, "probe requests succeeded")
+		}
+	case CircuitClosed:
+		if time.Since(cb.windowStart) > cb.opts.Window {
+			cb.windowStart = time.Now()
+			cb.requests = 0
+			cb.failures = 0
+		}
+
+		cb.requests++
+		if !success {
+			cb.failures++
+		}
+
+		if cb.requests >= cb.opts.MinimumRequests &&
+			float64(cb.failures)/float64(cb.requests) >= cb.opts.FailureRateThreshold {
+			cb.setState(ctx, CircuitOpen, "failure rate threshold reached")
+		}
+	}
+}
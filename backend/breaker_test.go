@@ -0,0 +1,206 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"load-balancer/requestid"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestBreaker_OpensOnFailureRate verifies the circuit opens once the failure rate is reached.
+func TestBreaker_OpensOnFailureRate(t *testing.T) {
+	cb := newCircuitBreaker("http://127.0.0.1:8080", BreakerOptions{FailureRateThreshold: 0.5, MinimumRequests: 4})
+
+	cb.record(context.Background(), true)
+	cb.record(context.Background(), false)
+	cb.record(context.Background(), false)
+	assert.Equal(t, CircuitClosed, cb.getState(), "minimum requests not reached")
+
+	cb.record(context.Background(), true)
+	assert.Equal(t, CircuitOpen, cb.getState())
+	assert.True(t, cb.isOpen())
+	assert.False(t, cb.allow())
+}
+
+// TestBreaker_LogsRequestID verifies a state change caused by a request is logged with its request id.
+func TestBreaker_LogsRequestID(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	cb := newCircuitBreaker("http://127.0.0.1:8080", BreakerOptions{MinimumRequests: 1, Logger: zap.New(core)})
+
+	cb.record(requestid.NewContext(context.Background(), "req-1"), false)
+
+	changes := logs.FilterMessage("circuit breaker state change")
+	require.Equal(t, 1, changes.Len())
+	assert.Equal(t, "req-1", changes.All()[0].ContextMap()["request_id"])
+}
+
+// TestBreaker_WindowReset verifies old results do not count once the window expired.
+func TestBreaker_WindowReset(t *testing.T) {
+	cb := newCircuitBreaker("http://127.0.0.1:8080", BreakerOptions{MinimumRequests: 2, Window: 20 * time.Millisecond})
+
+	cb.record(context.Background(), false)
+	time.Sleep(30 * time.Millisecond)
+	cb.record(context.Background(), false)
+
+	assert.Equal(t, CircuitClosed, cb.getState())
+}
+
+// TestBreaker_HalfOpenProbes verifies probing after the open timeout and closing after enough successes.
+func TestBreaker_HalfOpenProbes(t *testing.T) {
+	cb := newCircuitBreaker("http://127.0.0.1:8080", BreakerOptions{
+		MinimumRequests: 1,
+		OpenTimeout:     20 * time.Millisecond,
+		HalfOpenProbes:  2,
+	})
+
+	cb.record(context.Background(), false)
+	require.Equal(t, CircuitOpen, cb.getState())
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, CircuitHalfOpen, cb.getState())
+
+	// Only the configured number of probes is let through
+	assert.True(t, cb.allow())
+	assert.True(t, cb.allow())
+	assert.False(t, cb.allow())
+	assert.True(t, cb.isOpen())
+
+	cb.record(context.Background(), true)
+	assert.Equal(t, CircuitHalfOpen, cb.getState())
+	cb.record(context.Background(), true)
+	assert.Equal(t, CircuitClosed, cb.getState())
+	assert.True(t, cb.allow())
+}
+
+// TestBreaker_HalfOpenFailure verifies a failed probe opens the circuit again.
+func TestBreaker_HalfOpenFailure(t *testing.T) {
+	cb := newCircuitBreaker("http://127.0.0.1:8080", BreakerOptions{MinimumRequests: 1, OpenTimeout: 20 * time.Millisecond})
+
+	cb.record(context.Background(), false)
+	time.Sleep(30 * time.Millisecond)
+	require.True(t, cb.allow())
+
+	cb.record(context.Background(), false)
+	assert.Equal(t, CircuitOpen, cb.getState())
+}
+
+// TestBreaker_Backend verifies the breaker trips on live traffic and rejects requests without touching alive.
+func TestBreaker_Backend(t *testing.T) {
+	var hits atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+	b.SetCircuitBreaker(BreakerOptions{MinimumRequests: 3, OpenTimeout: time.Minute})
+
+	var lastErr error
+	b.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, e error) {
+		lastErr = e
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	for i := 0; i < 3; i++ {
+		b.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	assert.Equal(t, CircuitOpen, b.GetCircuitState())
+	assert.True(t, b.IsCircuitOpen())
+
+	rr := httptest.NewRecorder()
+	b.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.True(t, errors.Is(lastErr, ErrCircuitOpen))
+	assert.Equal(t, int32(3), hits.Load(), "rejected request must not reach the backend")
+
+	// Health checks flip alive, not the breaker
+	b.SetAlive(true)
+	assert.True(t, b.IsCircuitOpen())
+}
+
+// TestBreaker_ClientGone verifies requests cancelled by the client are not recorded as failures,
+// while attempts cut by the per-try timeout are.
+func TestBreaker_ClientGone(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+	b.SetCircuitBreaker(BreakerOptions{MinimumRequests: 1, OpenTimeout: time.Minute})
+
+	var gone []bool
+	b.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, e error) {
+		gone = append(gone, ClientGone(r))
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	b.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+	assert.Equal(t, []bool{true}, gone)
+	assert.Equal(t, CircuitClosed, b.GetCircuitState())
+
+	b.SetRetryPolicy(RetryPolicy{PerTryTimeout: 20 * time.Millisecond})
+	b.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, []bool{true, false}, gone)
+	assert.Equal(t, CircuitOpen, b.GetCircuitState())
+}
+
+// TestBreaker_AbandonedProbe verifies a half-open probe the client gave up on frees its slot.
+func TestBreaker_AbandonedProbe(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+	b.SetCircuitBreaker(BreakerOptions{MinimumRequests: 1, OpenTimeout: 20 * time.Millisecond, HalfOpenProbes: 1})
+	b.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, e error) {})
+
+	b.getBreaker().record(context.Background(), false)
+	time.Sleep(30 * time.Millisecond)
+	require.Equal(t, CircuitHalfOpen, b.GetCircuitState())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	b.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+	assert.Equal(t, CircuitHalfOpen, b.GetCircuitState())
+	assert.False(t, b.IsCircuitOpen())
+	assert.True(t, b.getBreaker().allow(), "the next request probes again")
+}
+
+// TestBreaker_Disabled verifies a backend without breaker is always closed.
+func TestBreaker_Disabled(t *testing.T) {
+	u, err := url.Parse("http://127.0.0.1:8080")
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+
+	assert.Equal(t, CircuitClosed, b.GetCircuitState())
+	assert.False(t, b.IsCircuitOpen())
+
+	// A breaker removed at runtime no longer rejects requests
+	b.SetCircuitBreaker(BreakerOptions{MinimumRequests: 1, OpenTimeout: time.Minute})
+	b.getBreaker().record(context.Background(), false)
+	require.True(t, b.IsCircuitOpen())
+	b.DisableCircuitBreaker()
+	assert.False(t, b.IsCircuitOpen())
+}
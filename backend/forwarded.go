@@ -0,0 +1,16 @@
+package backend
+
+import (
+	"load-balancer/forwarded"
+	"net/http/httputil"
+)
+
+// setForwardedHeaders sets the forwarding headers of the outgoing request from the forwarded.Info of its context.
+// A request that did not come through the load balancer is described by its connection alone.
+func setForwardedHeaders(pr *httputil.ProxyRequest) {
+	info, ok := forwarded.FromContext(pr.In.Context())
+	if !ok {
+		info = forwarded.Policy{}.Resolve(pr.In)
+	}
+	info.SetHeaders(pr.Out.Header)
+}
@@ -0,0 +1,54 @@
+package backend
+
+import (
+	"context"
+	"load-balancer/forwarded"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBackendForwardedHeaders verifies client forwarding headers are replaced by the ones of the forwarded.Info.
+func TestBackendForwardedHeaders(t *testing.T) {
+	var received http.Header
+	var host string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, host = r.Header.Clone(), r.Host
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "http://shop.example.com/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", "1.1.1.1")
+		req.Header.Set("X-Forwarded-Host", "evil.example.com")
+		req.Header.Set("Forwarded", "for=1.1.1.1")
+		return req
+	}
+
+	// Without forwarding information the connection is the client, spoofed headers are dropped
+	b.ServeHTTP(httptest.NewRecorder(), newRequest())
+	assert.Equal(t, "shop.example.com", host)
+	assert.Equal(t, "10.0.0.1", received.Get("X-Forwarded-For"))
+	assert.Equal(t, "shop.example.com", received.Get("X-Forwarded-Host"))
+	assert.Equal(t, "http", received.Get("X-Forwarded-Proto"))
+	assert.Empty(t, received.Values("Forwarded"))
+
+	// With forwarding information resolved by the load balancer, its headers are sent
+	req := newRequest()
+	policy := forwarded.Policy{Forwarded: true, TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}
+	req = req.WithContext(forwarded.NewContext(context.Background(), policy.Resolve(req)))
+	b.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "1.1.1.1, 10.0.0.1", received.Get("X-Forwarded-For"))
+	assert.Equal(t, "shop.example.com", received.Get("X-Forwarded-Host")) // Forwarded wins and reports no host
+	assert.Equal(t, []string{"for=1.1.1.1", "for=10.0.0.1;host=shop.example.com;proto=http"}, received.Values("Forwarded"))
+}
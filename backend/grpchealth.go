@@ -0,0 +1,187 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// grpcHealthPath is the method called by the gRPC health check.
+const grpcHealthPath = "/grpc.health.v1.Health/Check"
+
+// grpcServing is the SERVING value of grpc.health.v1.HealthCheckResponse.ServingStatus.
+const grpcServing = 1
+
+// grpcServingStatus names the values of grpc.health.v1.HealthCheckResponse.ServingStatus.
+var grpcServingStatus = map[uint64]string{
+	0: "UNKNOWN",
+	1: "SERVING",
+	2: "NOT_SERVING",
+	3: "SERVICE_UNKNOWN",
+}
+
+// grpcClient speaks HTTP/2 with prior knowledge (h2c) to http:// backends, and HTTP/2 over TLS to https:// backends.
+// Unencrypted HTTP/2 in net/http needs Go 1.24, which go.mod requires for it.
+var grpcClient = func() *http.Client {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Protocols = protocols
+	return &http.Client{Transport: transport}
+}()
+
+// GRPCHealthCheck calls grpc.health.v1.Health/Check on the backend.
+// The backend is healthy if it answers with the SERVING status.
+type GRPCHealthCheck struct {
+	Service string // service name sent in the request, empty checks the server as a whole
+}
+
+// Check sends the health check request to the backend.
+// Returns nil if the backend is serving, and the reason otherwise.
+func (hc GRPCHealthCheck) Check(ctx context.Context, b Backend) error {
+	u := *b.GetURL()
+	u.Path = grpcHealthPath
+	u.RawQuery = ""
+
+	// HealthCheckRequest{service = 1}
+	msg := appendProtoString(nil, 1, hc.Service)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(grpcFrame(msg)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+
+	resp, err := grpcClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	// Trailers are only populated once the body has been read
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHealthCheckBody))
+	if err != nil {
+		return fmt.Errorf("failed to read body: %w", err)
+	}
+
+	// A trailers-only response carries the status in the headers
+	status := resp.Trailer.Get("Grpc-Status")
+	message := resp.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status = resp.Header.Get("Grpc-Status")
+		message = resp.Header.Get("Grpc-Message")
+	}
+	if status != "0" {
+		return fmt.Errorf("grpc status %q: %s", status, message)
+	}
+
+	msg, err = parseGRPCFrame(body)
+	if err != nil {
+		return err
+	}
+
+	// HealthCheckResponse{status = 1}
+	serving, err := readProtoVarint(msg, 1)
+	if err != nil {
+		return err
+	}
+	if serving != grpcServing {
+		name, ok := grpcServingStatus[serving]
+		if !ok {
+			name = fmt.Sprint(serving)
+		}
+		return fmt.Errorf("serving status %s", name)
+	}
+
+	return nil
+}
+
+// grpcFrame prefixes an uncompressed message with the gRPC length-prefixed framing.
+func grpcFrame(msg []byte) []byte {
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	return append(frame, msg...)
+}
+
+// parseGRPCFrame returns the message of the first gRPC frame in body.
+func parseGRPCFrame(body []byte) ([]byte, error) {
+	if len(body) < 5 {
+		return nil, errors.New("grpc response too short")
+	}
+	if body[0] != 0 {
+		return nil, errors.New("compressed grpc response not supported")
+	}
+
+	n := binary.BigEndian.Uint32(body[1:5])
+	if uint32(len(body)-5) < n {
+		return nil, errors.New("grpc response truncated")
+	}
+	return body[5 : 5+n], nil
+}
+
+// appendProtoString appends a protobuf string field, skipping empty values like proto3 does.
+func appendProtoString(buf []byte, field int, s string) []byte {
+	if s == "" {
+		return buf
+	}
+	buf = binary.AppendUvarint(buf, uint64(field)<<3|2)
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// readProtoVarint returns the last value of a varint field of a protobuf message, 0 if absent.
+func readProtoVarint(msg []byte, field int) (uint64, error) {
+	var value uint64
+
+	for len(msg) > 0 {
+		tag, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return 0, errors.New("invalid protobuf tag")
+		}
+		msg = msg[n:]
+
+		// Skip fields by wire type
+		var size uint64
+		switch tag & 7 {
+		case 0: // varint
+			v, n := binary.Uvarint(msg)
+			if n <= 0 {
+				return 0, errors.New("invalid protobuf varint")
+			}
+			if tag>>3 == uint64(field) {
+				value = v
+			}
+			size = uint64(n)
+		case 1: // 64-bit
+			size = 8
+		case 2: // length-delimited
+			l, n := binary.Uvarint(msg)
+			if n <= 0 {
+				return 0, errors.New("invalid protobuf length")
+			}
+			size = uint64(n) + l
+		case 5: // 32-bit
+			size = 4
+		default:
+			return 0, fmt.Errorf("unsupported protobuf wire type %d", tag&7)
+		}
+
+		if uint64(len(msg)) < size {
+			return 0, errors.New("protobuf message truncated")
+		}
+		msg = msg[size:]
+	}
+
+	return value, nil
+}
@@ -0,0 +1,88 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGRPCHealthCheck verifies the serving status is read from the response.
+func TestGRPCHealthCheck(t *testing.T) {
+	// h2c server implementing grpc.health.v1.Health/Check, unknown services get a trailers-only NOT_FOUND
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || r.URL.Path != grpcHealthPath || r.Header.Get("Content-Type") != "application/grpc" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/grpc")
+
+		for service, status := range map[string]uint64{"": 1, "orders": 1, "payments": 2} {
+			if !bytes.Equal(body, grpcFrame(appendProtoString(nil, 1, service))) {
+				continue
+			}
+			w.Header().Set("Trailer", "Grpc-Status")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write(grpcFrame([]byte{0x08, byte(status)}))
+			w.Header().Set("Grpc-Status", "0")
+			return
+		}
+
+		w.Header().Set("Grpc-Status", strconv.Itoa(5))
+		w.Header().Set("Grpc-Message", "unknown service")
+		w.WriteHeader(http.StatusOK)
+	})
+
+	s := httptest.NewUnstartedServer(handler)
+	s.Config.Protocols = new(http.Protocols)
+	s.Config.Protocols.SetUnencryptedHTTP2(true)
+	s.Start()
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+
+	assert.NoError(t, GRPCHealthCheck{}.Check(context.Background(), b))
+	assert.NoError(t, GRPCHealthCheck{Service: "orders"}.Check(context.Background(), b))
+	assert.EqualError(t, GRPCHealthCheck{Service: "payments"}.Check(context.Background(), b), "serving status NOT_SERVING")
+	assert.EqualError(t, GRPCHealthCheck{Service: "users"}.Check(context.Background(), b), `grpc status "5": unknown service`)
+}
+
+// TestGRPCHealthCheck_NotGRPC verifies a plain HTTP/1 server is unhealthy.
+func TestGRPCHealthCheck_NotGRPC(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+
+	assert.Error(t, GRPCHealthCheck{}.Check(context.Background(), b))
+}
+
+// TestReadProtoVarint verifies the field is found among other fields and invalid messages are rejected.
+func TestReadProtoVarint(t *testing.T) {
+	// field 2 string "ab", field 1 varint 300, field 3 fixed32
+	msg := []byte{0x12, 0x02, 'a', 'b', 0x08, 0xac, 0x02, 0x1d, 0, 0, 0, 0}
+
+	v, err := readProtoVarint(msg, 1)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(300), v)
+
+	v, err = readProtoVarint(nil, 1)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), v, "absent field is the zero value")
+
+	_, err = readProtoVarint([]byte{0x12, 0x05, 'a'}, 1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,331 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxHealthCheckBody is the number of response body bytes inspected by body matching.
+const maxHealthCheckBody = 64 << 10
+
+// StatusRange is an inclusive range of HTTP status codes.
+type StatusRange struct {
+	From int
+	To   int
+}
+
+// ParseStatusRanges parses status codes ("204") and ranges ("200-299").
+func ParseStatusRanges(values []string) ([]StatusRange, error) {
+	ranges := make([]StatusRange, 0, len(values))
+
+	for _, v := range values {
+		from, to, isRange := strings.Cut(strings.TrimSpace(v), "-")
+		if !isRange {
+			to = from
+		}
+
+		lo, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil {
+			return nil, fmt.Errorf("invalid status %q: %w", v, err)
+		}
+		hi, err := strconv.Atoi(strings.TrimSpace(to))
+		if err != nil {
+			return nil, fmt.Errorf("invalid status %q: %w", v, err)
+		}
+		if lo < 100 || hi > 599 || lo > hi {
+			return nil, fmt.Errorf("invalid status %q", v)
+		}
+
+		ranges = append(ranges, StatusRange{From: lo, To: hi})
+	}
+
+	return ranges, nil
+}
+
+// HealthChecker probes a backend for the active health check.
+type HealthChecker interface {
+	// Check returns nil if the backend is healthy, and the reason otherwise.
+	Check(ctx context.Context, b Backend) error
+}
+
+// HealthCheck configures the active HTTP health check of a backend.
+// The zero value sends a GET to the backend root URL and expects a 200.
+type HealthCheck struct {
+	Path         string         // request path joined to the backend URL, may carry a query
+	Method       string         // request method (default GET)
+	Headers      http.Header    // extra request headers
+	Host         string         // Host header override
+	Statuses     []StatusRange  // accepted status codes (default 200)
+	BodyContains string         // substring the response body must contain
+	BodyRegex    *regexp.Regexp // pattern the response body must match
+}
+
+// request builds the health check request for the backend.
+func (hc HealthCheck) request(b Backend) (*http.Request, error) {
+	method := hc.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	u := *b.GetURL()
+	if hc.Path != "" {
+		// Only the path is joined, JoinPath would escape the query
+		path, err := url.Parse(hc.Path)
+		if err != nil {
+			return nil, err
+		}
+		u = *u.JoinPath(path.Path)
+		u.RawQuery = path.RawQuery
+	}
+
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range hc.Headers {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	if hc.Host != "" {
+		req.Host = hc.Host
+	}
+
+	return req, nil
+}
+
+// Check sends the health check request to the backend.
+// Returns nil if the backend is healthy, and the reason otherwise.
+func (hc HealthCheck) Check(ctx context.Context, b Backend) error {
+	req, err := hc.request(b)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return hc.check(resp)
+}
+
+// check validates the health check response.
+// Returns nil if the backend is healthy, and the reason otherwise.
+func (hc HealthCheck) check(resp *http.Response) error {
+	if !hc.statusAccepted(resp.StatusCode) {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	if hc.BodyContains == "" && hc.BodyRegex == nil {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHealthCheckBody))
+	if err != nil {
+		return fmt.Errorf("failed to read body: %w", err)
+	}
+	if hc.BodyContains != "" && !strings.Contains(string(body), hc.BodyContains) {
+		return fmt.Errorf("body does not contain %q", hc.BodyContains)
+	}
+	if hc.BodyRegex != nil && !hc.BodyRegex.Match(body) {
+		return fmt.Errorf("body does not match %q", hc.BodyRegex.String())
+	}
+
+	return nil
+}
+
+// statusAccepted reports whether the status code is accepted by the health check.
+func (hc HealthCheck) statusAccepted(status int) bool {
+	if len(hc.Statuses) == 0 {
+		return status == http.StatusOK
+	}
+
+	for _, r := range hc.Statuses {
+		if status >= r.From && status <= r.To {
+			return true
+		}
+	}
+	return false
+}
+
+// TCPHealthCheck is a connect-only health check: the backend is healthy if it accepts a TCP connection.
+type TCPHealthCheck struct {
+	Address string // host:port to dial (default the backend host, with the scheme port if none is set)
+}
+
+// Check dials the backend and closes the connection right away.
+// Returns nil if the connection was accepted, and the reason otherwise.
+func (hc TCPHealthCheck) Check(ctx context.Context, b Backend) error {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", hc.address(b))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// address returns the address dialed by the health check.
+func (hc TCPHealthCheck) address(b Backend) string {
+	if hc.Address != "" {
+		return hc.Address
+	}
+
+	u := b.GetURL()
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
+
+// HealthThresholds configures how probe results change the alive status of a backend.
+type HealthThresholds struct {
+	Healthy    int           // consecutive successful probes before a dead backend is marked alive (default 1)
+	Unhealthy  int           // consecutive failed probes before an alive backend is marked dead (default 1)
+	FlapLimit  int           // state changes allowed within FlapWindow before the backend is held down, 0 disables
+	FlapWindow time.Duration // window in which state changes are counted
+	HoldDown   time.Duration // time a flapping backend stays down
+}
+
+// HealthTransition describes the effect of a probe result on the alive status.
+type HealthTransition struct {
+	Alive   bool   // alive status after the probe
+	Changed bool   // the alive status changed
+	Reason  string // why the status changed
+}
+
+// ForcedState overrides the active health check of a backend.
+type ForcedState int
+
+const (
+	NotForced  ForcedState = iota // health checks decide the alive status
+	ForcedUp                      // alive regardless of health checks
+	ForcedDown                    // dead regardless of health checks
+)
+
+func (s ForcedState) String() string {
+	switch s {
+	case NotForced:
+		return "none"
+	case ForcedUp:
+		return "up"
+	case ForcedDown:
+		return "down"
+	default:
+		return "unknown"
+	}
+}
+
+// healthState tracks probe results of a backend between rounds.
+type healthState struct {
+	thresholds  HealthThresholds
+	forced      ForcedState // operator override of the probe results
+	successes   int         // consecutive successful probes
+	failures    int         // consecutive failed probes
+	transitions []time.Time // recent state changes, for flap detection
+	holdUntil   time.Time   // end of the flap hold-down
+}
+
+// SetHealthThresholds sets the rise/fall thresholds and flap damping of the backend.
+func (b *backend) SetHealthThresholds(t HealthThresholds) {
+	b.mux.Lock()
+	b.health.thresholds = t
+	b.mux.Unlock()
+}
+
+// SetForcedState forces the backend up or down, overriding health checks until NotForced is set.
+// Releasing the override keeps the current alive status until the health checks change it.
+func (b *backend) SetForcedState(state ForcedState) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.health.forced = state
+	switch state {
+	case ForcedUp:
+		b.setAlive(true)
+	case ForcedDown:
+		b.setAlive(false)
+	}
+}
+
+// GetForcedState returns the operator override of the backend health checks.
+func (b *backend) GetForcedState() ForcedState {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.health.forced
+}
+
+// ReportHealthCheck records an active health check result and updates the alive status
+// once the healthy or unhealthy threshold is reached. A backend changing state more than
+// FlapLimit times within FlapWindow is kept down for the HoldDown period.
+// A forced backend keeps its alive status.
+func (b *backend) ReportHealthCheck(healthy bool) HealthTransition {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	h := &b.health
+	now := time.Now()
+
+	if healthy {
+		h.successes++
+		h.failures = 0
+	} else {
+		h.failures++
+		h.successes = 0
+	}
+
+	if h.forced != NotForced || now.Before(h.holdUntil) {
+		return HealthTransition{Alive: b.alive}
+	}
+
+	var reason string
+	switch {
+	case !b.alive && healthy && h.successes >= max(h.thresholds.Healthy, 1):
+		reason = fmt.Sprintf("%d consecutive successful probes", h.successes)
+	case b.alive && !healthy && h.failures >= max(h.thresholds.Unhealthy, 1):
+		reason = fmt.Sprintf("%d consecutive failed probes", h.failures)
+	default:
+		return HealthTransition{Alive: b.alive}
+	}
+
+	if h.thresholds.FlapLimit > 0 {
+		// Keep only the state changes within the window, including this one
+		recent := h.transitions[:0]
+		for _, t := range h.transitions {
+			if now.Sub(t) <= h.thresholds.FlapWindow {
+				recent = append(recent, t)
+			}
+		}
+		h.transitions = append(recent, now)
+
+		if len(h.transitions) > h.thresholds.FlapLimit {
+			h.holdUntil = now.Add(h.thresholds.HoldDown)
+			h.transitions = nil
+			h.successes = 0
+
+			reason = fmt.Sprintf("flapping, %d state changes within %s, held down for %s",
+				h.thresholds.FlapLimit+1, h.thresholds.FlapWindow, h.thresholds.HoldDown)
+			if !b.alive {
+				// Already down, stays down
+				return HealthTransition{Alive: false, Reason: reason}
+			}
+			b.setAlive(false)
+			return HealthTransition{Alive: false, Changed: true, Reason: reason}
+		}
+	}
+
+	b.setAlive(healthy)
+	return HealthTransition{Alive: b.alive, Changed: true, Reason: reason}
+}
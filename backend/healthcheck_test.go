@@ -0,0 +1,268 @@
+package backend
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseStatusRanges verifies codes and ranges are parsed and invalid values rejected.
+func TestParseStatusRanges(t *testing.T) {
+	ranges, err := ParseStatusRanges([]string{"204", "200-299", " 300 - 308 "})
+	require.NoError(t, err)
+	assert.Equal(t, []StatusRange{{204, 204}, {200, 299}, {300, 308}}, ranges)
+
+	for _, invalid := range []string{"abc", "200-", "299-200", "99", "600"} {
+		_, err := ParseStatusRanges([]string{invalid})
+		assert.Error(t, err, invalid)
+	}
+}
+
+// TestHealthCheck_Default verifies the zero value expects a 200 from the root URL.
+func TestHealthCheck_Default(t *testing.T) {
+	var last *http.Request
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last = r
+		if r.URL.Path == "/healthz" {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+
+	// /healthz answers 204, not accepted by default
+	err = HealthCheck{Path: "/healthz"}.Check(context.Background(), b)
+	assert.ErrorContains(t, err, "unexpected status 204")
+
+	err = HealthCheck{Path: "/ready"}.Check(context.Background(), b)
+	assert.NoError(t, err)
+	assert.Equal(t, http.MethodGet, last.Method)
+}
+
+// TestHealthCheck_StatusPathMethodHeaders verifies path, method, headers, Host override and accepted statuses.
+func TestHealthCheck_StatusPathMethodHeaders(t *testing.T) {
+	var last *http.Request
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last = r
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+
+	hc := HealthCheck{
+		Path:     "/healthz",
+		Method:   http.MethodHead,
+		Headers:  http.Header{"X-Probe": []string{"lb"}},
+		Host:     "service.internal",
+		Statuses: []StatusRange{{200, 299}},
+	}
+
+	require.NoError(t, hc.Check(context.Background(), b))
+	assert.Equal(t, "/healthz", last.URL.Path)
+	assert.Equal(t, http.MethodHead, last.Method)
+	assert.Equal(t, "lb", last.Header.Get("X-Probe"))
+	assert.Equal(t, "service.internal", last.Host)
+}
+
+// TestHealthCheck_PathQuery verifies a query in the path is sent as a query, not escaped into the path.
+func TestHealthCheck_PathQuery(t *testing.T) {
+	var last *http.Request
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last = r
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+
+	require.NoError(t, HealthCheck{Path: "/ready?full=1"}.Check(context.Background(), b))
+	assert.Equal(t, "/ready", last.URL.Path)
+	assert.Equal(t, "full=1", last.URL.RawQuery)
+}
+
+// TestHealthCheck_BodyMatch verifies substring and regex body matching.
+func TestHealthCheck_BodyMatch(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status": "ready", "version": "1.2.3"}`))
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+
+	assert.NoError(t, HealthCheck{Path: "/ready", BodyContains: `"ready"`}.Check(context.Background(), b))
+	assert.Error(t, HealthCheck{Path: "/ready", BodyContains: "starting"}.Check(context.Background(), b))
+
+	assert.NoError(t, HealthCheck{Path: "/ready", BodyRegex: regexp.MustCompile(`"status":\s*"ready"`)}.Check(context.Background(), b))
+	assert.Error(t, HealthCheck{Path: "/ready", BodyRegex: regexp.MustCompile(`"version":\s*"2\.`)}.Check(context.Background(), b))
+}
+
+// TestHealthCheck_Unreachable verifies a refused connection is unhealthy.
+func TestHealthCheck_Unreachable(t *testing.T) {
+	u, err := url.Parse("http://127.0.0.1:65535")
+	require.NoError(t, err, "failed to parse url")
+
+	assert.Error(t, HealthCheck{}.Check(context.Background(), NewBackend(u)))
+}
+
+// TestTCPHealthCheck verifies a listening port is healthy and a closed one is not.
+func TestTCPHealthCheck(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "failed to listen")
+	defer l.Close()
+
+	u, err := url.Parse("tcp://" + l.Addr().String())
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+
+	assert.NoError(t, TCPHealthCheck{}.Check(context.Background(), b))
+
+	// The address overrides the backend host
+	assert.Error(t, TCPHealthCheck{Address: "127.0.0.1:65535"}.Check(context.Background(), b))
+
+	l.Close()
+	assert.Error(t, TCPHealthCheck{}.Check(context.Background(), b))
+}
+
+// TestTCPHealthCheck_DefaultPort verifies the scheme port is used when the backend URL has none.
+func TestTCPHealthCheck_DefaultPort(t *testing.T) {
+	for raw, want := range map[string]string{
+		"http://backend.internal":       "backend.internal:80",
+		"https://backend.internal":      "backend.internal:443",
+		"http://backend.internal:8080/": "backend.internal:8080",
+	} {
+		u, err := url.Parse(raw)
+		require.NoError(t, err, "failed to parse url")
+		assert.Equal(t, want, TCPHealthCheck{}.address(NewBackend(u)))
+	}
+}
+
+// TestGetHealthChecker_Default verifies a backend without checker uses the default HTTP check.
+func TestGetHealthChecker_Default(t *testing.T) {
+	u, err := url.Parse("http://127.0.0.1:8080")
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+
+	assert.Equal(t, HealthCheck{}, b.GetHealthChecker())
+
+	b.SetHealthChecker(TCPHealthCheck{})
+	assert.Equal(t, TCPHealthCheck{}, b.GetHealthChecker())
+}
+
+// TestReportHealthCheck_Thresholds verifies the alive status only changes after consecutive results.
+func TestReportHealthCheck_Thresholds(t *testing.T) {
+	u, err := url.Parse("http://127.0.0.1:8080")
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+	b.SetHealthThresholds(HealthThresholds{Healthy: 2, Unhealthy: 3})
+
+	assert.False(t, b.ReportHealthCheck(false).Changed)
+	assert.False(t, b.ReportHealthCheck(false).Changed)
+	assert.False(t, b.ReportHealthCheck(true).Changed, "success resets the failure count")
+	assert.False(t, b.ReportHealthCheck(false).Changed)
+	assert.False(t, b.ReportHealthCheck(false).Changed)
+	assert.True(t, b.IsAlive())
+
+	tr := b.ReportHealthCheck(false)
+	assert.Equal(t, HealthTransition{Alive: false, Changed: true, Reason: "3 consecutive failed probes"}, tr)
+	assert.False(t, b.IsAlive())
+
+	assert.False(t, b.ReportHealthCheck(true).Changed)
+	tr = b.ReportHealthCheck(true)
+	assert.Equal(t, HealthTransition{Alive: true, Changed: true, Reason: "2 consecutive successful probes"}, tr)
+	assert.True(t, b.IsAlive())
+}
+
+// TestReportHealthCheck_Default verifies a single result flips the status without thresholds.
+func TestReportHealthCheck_Default(t *testing.T) {
+	u, err := url.Parse("http://127.0.0.1:8080")
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+
+	assert.True(t, b.ReportHealthCheck(false).Changed)
+	assert.False(t, b.IsAlive())
+	assert.True(t, b.ReportHealthCheck(true).Changed)
+	assert.True(t, b.IsAlive())
+}
+
+// TestReportHealthCheck_FlapDamping verifies a flapping backend is held down.
+func TestReportHealthCheck_FlapDamping(t *testing.T) {
+	u, err := url.Parse("http://127.0.0.1:8080")
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+	b.SetHealthThresholds(HealthThresholds{FlapLimit: 3, FlapWindow: time.Minute, HoldDown: 30 * time.Millisecond})
+
+	b.ReportHealthCheck(false)
+	b.ReportHealthCheck(true)
+	b.ReportHealthCheck(false)
+
+	// The fourth state change within the window triggers the hold-down
+	tr := b.ReportHealthCheck(true)
+	assert.False(t, tr.Alive)
+	assert.False(t, tr.Changed)
+	assert.Contains(t, tr.Reason, "flapping")
+
+	// Successful probes are ignored during the hold-down
+	assert.False(t, b.ReportHealthCheck(true).Alive)
+	assert.False(t, b.IsAlive())
+
+	time.Sleep(40 * time.Millisecond)
+	assert.True(t, b.ReportHealthCheck(true).Changed)
+	assert.True(t, b.IsAlive())
+}
+
+// TestReportHealthCheck_FlapDampingWhileUp verifies a flapping backend that is up is taken down.
+func TestReportHealthCheck_FlapDampingWhileUp(t *testing.T) {
+	u, err := url.Parse("http://127.0.0.1:8080")
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+	b.SetHealthThresholds(HealthThresholds{FlapLimit: 1, FlapWindow: time.Minute, HoldDown: time.Minute})
+
+	b.ReportHealthCheck(false)
+	tr := b.ReportHealthCheck(true)
+
+	assert.False(t, tr.Alive)
+	assert.False(t, b.IsAlive())
+	assert.Contains(t, tr.Reason, "held down for 1m0s")
+}
+
+// TestForcedState verifies a forced backend ignores probe results until released.
+func TestForcedState(t *testing.T) {
+	u, err := url.Parse("http://127.0.0.1:8080")
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+
+	b.SetForcedState(ForcedDown)
+	assert.False(t, b.IsAlive())
+	assert.Equal(t, ForcedDown, b.GetForcedState())
+	assert.False(t, b.ReportHealthCheck(true).Changed)
+	assert.False(t, b.IsAlive())
+
+	b.SetForcedState(ForcedUp)
+	assert.True(t, b.IsAlive())
+	assert.False(t, b.ReportHealthCheck(false).Changed)
+	assert.True(t, b.IsAlive())
+
+	// Released, the health checks decide again
+	b.SetForcedState(NotForced)
+	assert.True(t, b.IsAlive())
+	assert.True(t, b.ReportHealthCheck(false).Changed)
+	assert.False(t, b.IsAlive())
+}
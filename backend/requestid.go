@@ -0,0 +1,21 @@
+package backend
+
+import (
+	"load-balancer/requestid"
+	"net/http"
+)
+
+// forwardRequestID sets the X-Request-ID of the outgoing request to the id of its context.
+func forwardRequestID(r *http.Request) {
+	if id := requestid.FromContext(r.Context()); id != "" {
+		r.Header.Set(requestid.Header, id)
+	}
+}
+
+// echoRequestID keeps the X-Request-ID of the upstream response from duplicating the one
+// the load balancer already set on the client response.
+func echoRequestID(resp *http.Response) {
+	if id := requestid.FromContext(resp.Request.Context()); id != "" {
+		resp.Header.Del(requestid.Header)
+	}
+}
@@ -0,0 +1,44 @@
+package backend
+
+import (
+	"context"
+	"load-balancer/requestid"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBackendRequestID verifies the request id of the context is forwarded and the upstream echo dropped.
+func TestBackendRequestID(t *testing.T) {
+	var received string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(requestid.Header)
+		w.Header().Set(requestid.Header, "upstream")
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+
+	// Without an id the request and response pass through unchanged
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(requestid.Header, "client")
+	rr := httptest.NewRecorder()
+	b.ServeHTTP(rr, req)
+	assert.Equal(t, "client", received)
+	assert.Equal(t, "upstream", rr.Header().Get(requestid.Header))
+
+	// With an id the context wins and the load balancer echoes it
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(requestid.Header, "client")
+	req = req.WithContext(requestid.NewContext(context.Background(), "req-42"))
+	rr = httptest.NewRecorder()
+	b.ServeHTTP(rr, req)
+	assert.Equal(t, "req-42", received)
+	assert.Empty(t, rr.Header().Get(requestid.Header))
+}
@@ -0,0 +1,76 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// StatusError is returned to the error handler when a backend answers with a retryable status code.
+// The response is discarded so the request can be retried on another backend.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("retryable upstream status %d", e.StatusCode)
+}
+
+// ErrPerTryTimeout is the error of an attempt cut by the per-try timeout of the retry policy.
+var ErrPerTryTimeout = errors.New("per-try timeout exceeded")
+
+// ClientGone reports whether the client cancelled the request or its deadline passed.
+// An attempt cut by the per-try timeout does not count, the client is still waiting.
+func ClientGone(r *http.Request) bool {
+	ctx := r.Context()
+	return ctx.Err() != nil && !errors.Is(context.Cause(ctx), ErrPerTryTimeout)
+}
+
+// RetryPolicy configures which upstream responses are treated as failures.
+type RetryPolicy struct {
+	Statuses      []int                    // status codes retried on another backend
+	Methods       []string                 // methods retried on a retryable status, empty means any method
+	PerTryTimeout time.Duration            // time allowed for one attempt, until the response is copied, 0 disables
+	AllowRetry    func(*http.Request) bool // reports whether the request still has attempts left
+}
+
+// retryable reports whether the response should be discarded and the request retried.
+func (p RetryPolicy) retryable(resp *http.Response) bool {
+	if !slices.Contains(p.Statuses, resp.StatusCode) {
+		return false
+	}
+	if len(p.Methods) > 0 && !slices.Contains(p.Methods, resp.Request.Method) {
+		return false
+	}
+	// Keep the response if it is the last attempt, the client is better served by it than by a generic error
+	return p.AllowRetry == nil || p.AllowRetry(resp.Request)
+}
+
+// SetRetryPolicy makes the backend report retryable responses to its error handler and applies the per-try timeout.
+// It can be changed while requests are served, attempts in flight keep the policy they started with.
+func (b *backend) SetRetryPolicy(p RetryPolicy) {
+	b.mux.Lock()
+	b.retryPolicy = p
+	b.mux.Unlock()
+}
+
+// getRetryPolicy returns the current retry policy.
+func (b *backend) getRetryPolicy() RetryPolicy {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.retryPolicy
+}
+
+// withPerTryTimeout bounds an attempt by the per-try timeout of the policy, from connecting and sending
+// the body until the response is copied. The returned cancel func must be called once the attempt is over.
+// An attempt cut by the timeout fails with ErrPerTryTimeout as the cause of its context.
+func (p RetryPolicy) withPerTryTimeout(r *http.Request) (*http.Request, context.CancelFunc) {
+	if p.PerTryTimeout <= 0 {
+		return r, func() {}
+	}
+	ctx, cancel := context.WithTimeoutCause(r.Context(), p.PerTryTimeout, ErrPerTryTimeout)
+	return r.WithContext(ctx), cancel
+}
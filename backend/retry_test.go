@@ -0,0 +1,154 @@
+package backend
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRetryPolicy_RetryableStatus verifies a retryable status is discarded and reported to the error handler.
+func TestRetryPolicy_RetryableStatus(t *testing.T) {
+	var errs []error
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("upstream body"))
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+	b.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, e error) {
+		errs = append(errs, e)
+		w.WriteHeader(http.StatusTeapot)
+	})
+	b.SetRetryPolicy(RetryPolicy{Statuses: []int{502, 503, 504}})
+
+	rr := httptest.NewRecorder()
+	b.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusTeapot, rr.Code)
+	require.Len(t, errs, 1)
+
+	var statusErr *StatusError
+	require.True(t, errors.As(errs[0], &statusErr))
+	assert.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+}
+
+// TestRetryPolicy_NonRetryable verifies other statuses and methods pass through unchanged.
+func TestRetryPolicy_NonRetryable(t *testing.T) {
+	var errs []error
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte("upstream body"))
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+	b.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, e error) {
+		errs = append(errs, e)
+		w.WriteHeader(http.StatusTeapot)
+	})
+	b.SetRetryPolicy(RetryPolicy{Statuses: []int{502, 503, 504}})
+
+	rr := httptest.NewRecorder()
+	b.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Empty(t, errs)
+
+	s2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte("upstream body"))
+	}))
+	defer s2.Close()
+
+	u, err = url.Parse(s2.URL)
+	require.NoError(t, err, "failed to parse url")
+	b = NewBackend(u)
+	b.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, e error) {
+		errs = append(errs, e)
+		w.WriteHeader(http.StatusTeapot)
+	})
+	b.SetRetryPolicy(RetryPolicy{Statuses: []int{502}, Methods: []string{http.MethodGet}})
+
+	rr = httptest.NewRecorder()
+	b.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", nil))
+
+	assert.Equal(t, http.StatusBadGateway, rr.Code)
+	assert.Equal(t, "upstream body", rr.Body.String())
+	assert.Empty(t, errs)
+}
+
+// TestRetryPolicy_LastAttempt verifies the upstream response is kept when no retry is left.
+func TestRetryPolicy_LastAttempt(t *testing.T) {
+	var errs []error
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("upstream body"))
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+	b.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, e error) {
+		errs = append(errs, e)
+		w.WriteHeader(http.StatusTeapot)
+	})
+	b.SetRetryPolicy(RetryPolicy{
+		Statuses:   []int{503},
+		AllowRetry: func(*http.Request) bool { return false },
+	})
+
+	rr := httptest.NewRecorder()
+	b.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "upstream body", rr.Body.String())
+	assert.Empty(t, errs)
+}
+
+// TestRetryPolicy_PerTryTimeout verifies a slow backend is abandoned after the per-try timeout.
+func TestRetryPolicy_PerTryTimeout(t *testing.T) {
+	var errs []error
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("upstream body"))
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+	b.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, e error) {
+		errs = append(errs, e)
+		w.WriteHeader(http.StatusTeapot)
+	})
+	b.SetRetryPolicy(RetryPolicy{PerTryTimeout: 50 * time.Millisecond})
+
+	start := time.Now()
+	rr := httptest.NewRecorder()
+	b.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Less(t, time.Since(start), 400*time.Millisecond)
+	assert.Equal(t, http.StatusTeapot, rr.Code)
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], ErrPerTryTimeout)
+
+	// A zero timeout lifts the deadline again
+	b.SetRetryPolicy(RetryPolicy{})
+	rr = httptest.NewRecorder()
+	b.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Len(t, errs, 1)
+}
@@ -0,0 +1,40 @@
+package backend
+
+import "time"
+
+// slowStartMinFactor is the share of traffic a backend gets right after it recovered.
+const slowStartMinFactor = 0.1
+
+// SetSlowStart sets the time over which a recovered backend ramps up to its full share of traffic.
+// Zero disables slow start.
+func (b *backend) SetSlowStart(d time.Duration) {
+	b.mux.Lock()
+	b.slowStart = d
+	b.mux.Unlock()
+}
+
+// GetSlowStartFactor returns the share of its normal traffic the backend should get, in (0, 1].
+// It ramps linearly from slowStartMinFactor to 1 over the slow start period after the backend
+// was marked alive again, and is 1 outside slow start.
+func (b *backend) GetSlowStartFactor() float64 {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+
+	if b.slowStart <= 0 || b.recoveredAt.IsZero() {
+		return 1
+	}
+
+	elapsed := time.Since(b.recoveredAt)
+	if elapsed >= b.slowStart {
+		return 1
+	}
+	return slowStartMinFactor + (1-slowStartMinFactor)*float64(elapsed)/float64(b.slowStart)
+}
+
+// setAlive updates the alive status, starting slow start when a dead backend recovers. Caller must hold b.mux.
+func (b *backend) setAlive(alive bool) {
+	if alive && !b.alive {
+		b.recoveredAt = time.Now()
+	}
+	b.alive = alive
+}
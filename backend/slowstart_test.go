@@ -0,0 +1,57 @@
+package backend
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSlowStart_Ramp verifies the factor ramps from the minimum to full after recovery.
+func TestSlowStart_Ramp(t *testing.T) {
+	u, err := url.Parse("http://127.0.0.1:8080")
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackendWithOptions(u, Options{SlowStart: 100 * time.Millisecond})
+
+	assert.Equal(t, 1.0, b.GetSlowStartFactor(), "a new backend gets its full share")
+
+	b.SetAlive(false)
+	b.SetAlive(true)
+	assert.InDelta(t, slowStartMinFactor, b.GetSlowStartFactor(), 0.05)
+
+	time.Sleep(50 * time.Millisecond)
+	f := b.GetSlowStartFactor()
+	assert.Greater(t, f, 0.4)
+	assert.Less(t, f, 1.0)
+
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, 1.0, b.GetSlowStartFactor())
+}
+
+// TestSlowStart_HealthCheckRecovery verifies a health check recovery starts slow start, staying alive does not.
+func TestSlowStart_HealthCheckRecovery(t *testing.T) {
+	u, err := url.Parse("http://127.0.0.1:8080")
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+	b.SetSlowStart(time.Hour)
+
+	b.SetAlive(true)
+	assert.Equal(t, 1.0, b.GetSlowStartFactor())
+
+	b.ReportHealthCheck(false)
+	b.ReportHealthCheck(true)
+	assert.InDelta(t, slowStartMinFactor, b.GetSlowStartFactor(), 0.01)
+}
+
+// TestSlowStart_Disabled verifies recovery without slow start gives the full share right away.
+func TestSlowStart_Disabled(t *testing.T) {
+	u, err := url.Parse("http://127.0.0.1:8080")
+	require.NoError(t, err, "failed to parse url")
+	b := NewBackend(u)
+
+	b.SetAlive(false)
+	b.SetAlive(true)
+	assert.Equal(t, 1.0, b.GetSlowStartFactor())
+}
@@ -0,0 +1,68 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"load-balancer/tracing"
+	"net/http"
+)
+
+// attemptNumberKey is the context key of the attempt number of a request.
+type attemptNumberKey struct{}
+
+// WithAttemptNumber records the attempt number of a request, 1 for the first try,
+// so the attempt span can tell retries apart.
+func WithAttemptNumber(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, attemptNumberKey{}, n)
+}
+
+// attemptNumber returns the attempt number of the request, 1 if none was recorded.
+func attemptNumber(ctx context.Context) int {
+	n, ok := ctx.Value(attemptNumberKey{}).(int)
+	if !ok || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// SetTracer makes the backend record a client span for every attempt, child of the span in the request context.
+// A nil tracer disables tracing.
+func (b *backend) SetTracer(t *tracing.Tracer) {
+	b.tracer = t
+}
+
+// startAttemptSpan starts the span of an attempt and returns the request carrying it.
+func (b *backend) startAttemptSpan(r *http.Request) (*http.Request, *tracing.Span) {
+	ctx, span := b.tracer.Start(r.Context(), "upstream "+r.Method, tracing.SpanKindClient)
+	span.SetAttribute("http.request.method", r.Method)
+	span.SetAttribute("url.path", r.URL.Path)
+	span.SetAttribute("lb.backend.url", b.url.String())
+	span.SetAttribute("lb.retry", attemptNumber(ctx)-1)
+	return r.WithContext(ctx), span
+}
+
+// endAttemptSpan records the outcome of an attempt on its span.
+// A failed attempt ends its span at once, before the retries it triggers.
+func endAttemptSpan(r *http.Request, statusCode int, err error) {
+	span := tracing.SpanFromContext(r.Context())
+	if statusCode > 0 {
+		span.SetAttribute("http.response.status_code", statusCode)
+	}
+	if err == nil && statusCode >= http.StatusInternalServerError {
+		span.SetError(fmt.Errorf("upstream status %d", statusCode))
+	}
+	if err != nil {
+		span.SetError(err)
+		span.End()
+	}
+}
+
+// injectTraceparent propagates the attempt span to the backend.
+func (b *backend) injectTraceparent(r *http.Request) {
+	if b.tracer == nil {
+		return
+	}
+	if span := tracing.SpanFromContext(r.Context()); span != nil {
+		tracing.Inject(span.SpanContext(), r.Header)
+	}
+}
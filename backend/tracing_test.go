@@ -0,0 +1,42 @@
+package backend
+
+import (
+	"context"
+	"load-balancer/tracing"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBackendTracing verifies that an attempt span, child of the request span, is propagated to the upstream.
+func TestBackendTracing(t *testing.T) {
+	var received string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(tracing.TraceparentHeader)
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err, "failed to parse url")
+
+	// Nothing listens on the endpoint, spans are not checked here
+	tracer := tracing.NewTracer(tracing.Options{Endpoint: "http://127.0.0.1:0/v1/traces"})
+	defer tracer.Shutdown(context.Background())
+
+	b := NewBackendWithOptions(u, Options{Tracer: tracer})
+
+	ctx, parent := tracer.Start(context.Background(), "GET", tracing.SpanKindServer)
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(WithAttemptNumber(ctx, 2))
+	b.ServeHTTP(httptest.NewRecorder(), req)
+
+	sc, ok := tracing.ParseTraceparent(received)
+	require.True(t, ok, "traceparent expected, got %q", received)
+	assert.Equal(t, parent.SpanContext().TraceID, sc.TraceID)
+	assert.NotEqual(t, parent.SpanContext().SpanID, sc.SpanID)
+	assert.Equal(t, 2, attemptNumber(req.Context()))
+	assert.Equal(t, 1, attemptNumber(context.Background()))
+}
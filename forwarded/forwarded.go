@@ -0,0 +1,287 @@
+package forwarded
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Forwarding headers set on requests sent to backends.
+const (
+	HeaderForwarded       = "Forwarded" // RFC 7239
+	HeaderXForwardedFor   = "X-Forwarded-For"
+	HeaderXForwardedHost  = "X-Forwarded-Host"
+	HeaderXForwardedProto = "X-Forwarded-Proto"
+)
+
+// unknownNode replaces reported hops that are not addresses in the headers sent to backends, see RFC 7239 section 6.2.
+const unknownNode = "unknown"
+
+// Mode tells how the forwarding headers received from a trusted proxy are passed on.
+type Mode string
+
+const (
+	ModeAppend    Mode = "append"    // keep the hops reported by trusted proxies and add the client connection
+	ModeOverwrite Mode = "overwrite" // replace the headers with the resolved client only
+)
+
+// ParseMode returns the mode of a setting, append when empty.
+func ParseMode(s string) (Mode, error) {
+	switch Mode(strings.ToLower(s)) {
+	case "", ModeAppend:
+		return ModeAppend, nil
+	case ModeOverwrite:
+		return ModeOverwrite, nil
+	default:
+		return "", fmt.Errorf("unknown forwarded headers mode %q", s)
+	}
+}
+
+// Policy configures how the client of a request is resolved and which forwarding headers backends receive.
+// The zero value trusts no proxy, appends and sets the X-Forwarded-* headers only.
+type Policy struct {
+	Mode           Mode           // append (default) or overwrite
+	Forwarded      bool           // also set the RFC 7239 Forwarded header
+	TrustedProxies []netip.Prefix // peers whose forwarding headers are believed
+}
+
+// ParseTrustedProxies parses a list of CIDR ranges or single addresses.
+func ParseTrustedProxies(proxies []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if !strings.Contains(p, "/") {
+			addr, err := netip.ParseAddr(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", p, err)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", p, err)
+		}
+		if prefix.Addr().Is4In6() {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// trusts reports whether the address belongs to a trusted proxy.
+func (p Policy) trusts(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range p.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Info is the forwarding information of a request, resolved once when it enters the load balancer.
+type Info struct {
+	ClientIP string // address of the client, as reported by trusted proxies
+	Proto    string // scheme the client used, http or https
+	Host     string // host the client asked for
+
+	policy    Policy
+	peer      string   // address of the connection peer
+	hops      []string // addresses reported by the trusted peer, client first
+	received  []string // Forwarded elements reported by the trusted peer
+	ownHost   string   // Host of the request received by the load balancer
+	ownProto  string   // scheme of the connection to the load balancer
+	fromPeers bool     // the peer is trusted, its headers were read
+}
+
+// Resolve works out the client, scheme and host of a request.
+// The forwarding headers are only read when the connection comes from a trusted proxy.
+// The client is then the rightmost reported address that is not a trusted proxy,
+// or the leftmost one if every hop is trusted. A hop that is not an address before the client is
+// reached leaves the client unknown, the peer is used then.
+func (p Policy) Resolve(r *http.Request) *Info {
+	info := &Info{
+		policy:   p,
+		peer:     remoteIP(r.RemoteAddr),
+		ownHost:  r.Host,
+		ownProto: scheme(r),
+	}
+	info.ClientIP, info.Host, info.Proto = info.peer, info.ownHost, info.ownProto
+
+	peer, err := netip.ParseAddr(info.peer)
+	if err != nil || !p.trusts(peer) {
+		return info
+	}
+	info.fromPeers = true
+
+	// Forwarded wins over X-Forwarded-* when both are sent, a malformed one is ignored
+	var hosts, protos []string
+	if elements, ok := parseForwarded(r.Header.Values(HeaderForwarded)); ok && len(elements) > 0 {
+		info.received = r.Header.Values(HeaderForwarded)
+		for _, e := range elements {
+			info.hops = append(info.hops, e.node)
+			hosts = append(hosts, e.host)
+			protos = append(protos, e.proto)
+		}
+	} else {
+		info.hops = splitList(r.Header.Values(HeaderXForwardedFor))
+		hosts = splitList(r.Header.Values(HeaderXForwardedHost))
+		protos = splitList(r.Header.Values(HeaderXForwardedProto))
+	}
+
+	// Walk from the closest hop until one is not a trusted proxy
+	client := len(info.hops)
+	for i := len(info.hops) - 1; i >= 0; i-- {
+		addr, ok := parseNode(info.hops[i])
+		if !ok {
+			// Obfuscated or garbage, the trusted hops seen so far are not the client
+			info.ClientIP, client = info.peer, len(info.hops)
+			break
+		}
+		info.ClientIP, client = addr.String(), i
+		if !p.trusts(addr) {
+			break
+		}
+	}
+
+	// Host and scheme as reported by the proxy the client connected to, X-Forwarded-* lists hold one value
+	if i := min(client, len(hosts)-1); i >= 0 && validHost(hosts[i]) {
+		info.Host = hosts[i]
+	}
+	if i := min(client, len(protos)-1); i >= 0 {
+		if proto := strings.ToLower(protos[i]); proto == "http" || proto == "https" {
+			info.Proto = proto
+		}
+	}
+
+	return info
+}
+
+// SetHeaders sets the forwarding headers of a request sent to a backend, replacing any present.
+func (i *Info) SetHeaders(h http.Header) {
+	h.Del(HeaderForwarded)
+
+	if i.policy.Mode == ModeOverwrite || !i.fromPeers {
+		h.Set(HeaderXForwardedFor, i.ClientIP)
+		h.Set(HeaderXForwardedHost, i.Host)
+		h.Set(HeaderXForwardedProto, i.Proto)
+		if i.policy.Forwarded {
+			h.Set(HeaderForwarded, formatElement(i.ClientIP, i.Host, i.Proto))
+		}
+		return
+	}
+
+	xff := make([]string, 0, len(i.hops)+1)
+	for _, hop := range i.hops {
+		if addr, ok := parseNode(hop); ok {
+			hop = addr.String() // drop ports and brackets of Forwarded nodes
+		} else {
+			hop = unknownNode
+		}
+		xff = append(xff, hop)
+	}
+	h.Set(HeaderXForwardedFor, strings.Join(append(xff, i.peer), ", "))
+	h.Set(HeaderXForwardedHost, i.Host)
+	h.Set(HeaderXForwardedProto, i.Proto)
+	if !i.policy.Forwarded {
+		return
+	}
+
+	elements := i.received
+	if elements == nil {
+		for _, hop := range i.hops {
+			if _, ok := parseNode(hop); !ok {
+				hop = unknownNode
+			}
+			elements = append(elements, "for="+formatNode(hop))
+		}
+	}
+	h[HeaderForwarded] = append(elements[:len(elements):len(elements)], formatElement(i.peer, i.ownHost, i.ownProto))
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying the forwarding information of a request.
+func NewContext(ctx context.Context, info *Info) context.Context {
+	return context.WithValue(ctx, contextKey{}, info)
+}
+
+// FromContext returns the forwarding information of the context, false if there is none.
+func FromContext(ctx context.Context) (*Info, bool) {
+	info, ok := ctx.Value(contextKey{}).(*Info)
+	return info, ok
+}
+
+// ClientIP returns the resolved client of the request, the host of its remote address if it was not resolved.
+func ClientIP(r *http.Request) string {
+	if info, ok := FromContext(r.Context()); ok {
+		return info.ClientIP
+	}
+	return remoteIP(r.RemoteAddr)
+}
+
+// remoteIP returns the host part of a remote address.
+func remoteIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+func scheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// splitList splits comma separated header values into trimmed, non empty items.
+func splitList(values []string) []string {
+	var items []string
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// parseNode parses an address reported by a proxy: an IP, optionally with a port, IPv6 optionally in brackets.
+// Obfuscated and "unknown" nodes are not addresses.
+func parseNode(node string) (netip.Addr, bool) {
+	if addr, err := netip.ParseAddr(node); err == nil {
+		return addr.Unmap(), true
+	}
+	if addrPort, err := netip.ParseAddrPort(node); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	if strings.HasPrefix(node, "[") && strings.HasSuffix(node, "]") {
+		if addr, err := netip.ParseAddr(node[1 : len(node)-1]); err == nil {
+			return addr.Unmap(), true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// validHost reports whether a reported host can be passed on: no spaces, control characters or list separators.
+func validHost(host string) bool {
+	if host == "" {
+		return false
+	}
+	for i := 0; i < len(host); i++ {
+		if c := host[i]; c <= ' ' || c >= 0x7f || c == ',' || c == ';' || c == '"' {
+			return false
+		}
+	}
+	return true
+}
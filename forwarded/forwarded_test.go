@@ -0,0 +1,219 @@
+package forwarded
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPolicy returns a policy trusting the given proxies.
+func newPolicy(t *testing.T, mode Mode, emitForwarded bool, trusted ...string) Policy {
+	t.Helper()
+
+	prefixes, err := ParseTrustedProxies(trusted)
+	require.NoError(t, err, "failed to parse trusted proxies")
+	return Policy{Mode: mode, Forwarded: emitForwarded, TrustedProxies: prefixes}
+}
+
+// newRequest returns a request from the remote address carrying the given headers.
+func newRequest(remoteAddr string, headers map[string]string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.RemoteAddr = remoteAddr
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return req
+}
+
+// Test trusted proxies accept CIDR ranges and single addresses, IPv4-mapped addresses are unmapped
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := ParseTrustedProxies([]string{"10.0.0.0/8", " 192.168.1.7 ", "2001:db8::/32", "::ffff:172.16.0.0/108"})
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.168.1.7/32"),
+		netip.MustParsePrefix("2001:db8::/32"),
+		netip.MustParsePrefix("172.16.0.0/12"),
+	}, prefixes)
+
+	for _, invalid := range []string{"10.0.0.0/33", "proxy.local", ""} {
+		_, err := ParseTrustedProxies([]string{invalid})
+		assert.Error(t, err, invalid)
+	}
+}
+
+// Test modes parse case-insensitively and default to append
+func TestParseMode(t *testing.T) {
+	for s, want := range map[string]Mode{"": ModeAppend, "append": ModeAppend, "Overwrite": ModeOverwrite} {
+		mode, err := ParseMode(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, want, mode, s)
+	}
+
+	_, err := ParseMode("replace")
+	assert.Error(t, err)
+}
+
+// Test the client is the connection peer unless the peer is a trusted proxy
+func TestResolve_ClientIP(t *testing.T) {
+	p := newPolicy(t, ModeAppend, false, "10.0.0.0/8", "2001:db8::/32")
+
+	for _, tc := range []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{"untrusted peer spoofing", "203.0.113.9:1234", map[string]string{"X-Forwarded-For": "1.1.1.1"}, "203.0.113.9"},
+		{"trusted peer without headers", "10.0.0.1:1234", nil, "10.0.0.1"},
+		{"trusted peer", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.4"}, "198.51.100.4"},
+		{"rightmost untrusted hop", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.4, 10.0.0.2"}, "198.51.100.4"},
+		{"every hop trusted", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"hop with port", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.4:5555"}, "198.51.100.4"},
+		{"unknown hop", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.1.1.1, unknown, 10.0.0.2"}, "10.0.0.1"},
+		{"garbage hop", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.1.1.1, <script>"}, "10.0.0.1"},
+		{"garbage hop behind client", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "<script>, 198.51.100.4"}, "198.51.100.4"},
+		{"ipv6 peer", "[2001:db8::1]:1234", map[string]string{"X-Forwarded-For": "2001:db9::7"}, "2001:db9::7"},
+		{"forwarded header", "10.0.0.1:1234", map[string]string{"Forwarded": `for=198.51.100.4, for="[2001:db9::7]:4711"`, "X-Forwarded-For": "1.1.1.1"}, "2001:db9::7"},
+		{"malformed forwarded header", "10.0.0.1:1234", map[string]string{"Forwarded": `for="unterminated`, "X-Forwarded-For": "198.51.100.4"}, "198.51.100.4"},
+	} {
+		info := p.Resolve(newRequest(tc.remoteAddr, tc.headers))
+		assert.Equal(t, tc.want, info.ClientIP, tc.name)
+	}
+}
+
+// Test the scheme and host are only taken from a trusted peer and must be valid
+func TestResolve_ProtoHost(t *testing.T) {
+	p := newPolicy(t, ModeAppend, false, "10.0.0.0/8")
+	headers := map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "shop.example.com"}
+
+	info := p.Resolve(newRequest("203.0.113.9:1234", headers))
+	assert.Equal(t, "http", info.Proto)
+	assert.Equal(t, "example.com", info.Host)
+
+	info = p.Resolve(newRequest("10.0.0.1:1234", headers))
+	assert.Equal(t, "https", info.Proto)
+	assert.Equal(t, "shop.example.com", info.Host)
+
+	info = p.Resolve(newRequest("10.0.0.1:1234", map[string]string{"X-Forwarded-Proto": "gopher", "X-Forwarded-Host": "a b"}))
+	assert.Equal(t, "http", info.Proto)
+	assert.Equal(t, "example.com", info.Host)
+
+	req := newRequest("203.0.113.9:1234", nil)
+	req.TLS = &tls.ConnectionState{}
+	assert.Equal(t, "https", p.Resolve(req).Proto)
+
+	// Forwarded reports the host and scheme of the element added for the client
+	info = p.Resolve(newRequest("10.0.0.1:1234", map[string]string{
+		"Forwarded": `for=198.51.100.4;host="shop.example.com:8443";proto=https, for=10.0.0.2;host=internal;proto=http`,
+	}))
+	assert.Equal(t, "https", info.Proto)
+	assert.Equal(t, "shop.example.com:8443", info.Host)
+}
+
+// Test the headers sent to backends in append and overwrite mode
+func TestSetHeaders(t *testing.T) {
+	headers := map[string]string{
+		"X-Forwarded-For":   "1.1.1.1, 198.51.100.4",
+		"X-Forwarded-Proto": "https",
+		"X-Forwarded-Host":  "shop.example.com",
+	}
+
+	for _, tc := range []struct {
+		name       string
+		policy     Policy
+		remoteAddr string
+		want       http.Header
+	}{
+		{
+			name:       "append from trusted peer",
+			policy:     newPolicy(t, ModeAppend, true, "10.0.0.0/8"),
+			remoteAddr: "10.0.0.1:1234",
+			want: http.Header{
+				"X-Forwarded-For":   {"1.1.1.1, 198.51.100.4, 10.0.0.1"},
+				"X-Forwarded-Host":  {"shop.example.com"},
+				"X-Forwarded-Proto": {"https"},
+				"Forwarded":         {"for=1.1.1.1", "for=198.51.100.4", "for=10.0.0.1;host=example.com;proto=http"},
+			},
+		},
+		{
+			name:       "overwrite from trusted peer",
+			policy:     newPolicy(t, ModeOverwrite, true, "10.0.0.0/8"),
+			remoteAddr: "10.0.0.1:1234",
+			want: http.Header{
+				"X-Forwarded-For":   {"198.51.100.4"},
+				"X-Forwarded-Host":  {"shop.example.com"},
+				"X-Forwarded-Proto": {"https"},
+				"Forwarded":         {"for=198.51.100.4;host=shop.example.com;proto=https"},
+			},
+		},
+		{
+			name:       "untrusted peer",
+			policy:     newPolicy(t, ModeAppend, false, "10.0.0.0/8"),
+			remoteAddr: "[2001:db8::1]:1234",
+			want: http.Header{
+				"X-Forwarded-For":   {"2001:db8::1"},
+				"X-Forwarded-Host":  {"example.com"},
+				"X-Forwarded-Proto": {"http"},
+			},
+		},
+		{
+			name:       "untrusted ipv6 peer with forwarded",
+			policy:     newPolicy(t, ModeAppend, true),
+			remoteAddr: "[2001:db8::1]:1234",
+			want: http.Header{
+				"X-Forwarded-For":   {"2001:db8::1"},
+				"X-Forwarded-Host":  {"example.com"},
+				"X-Forwarded-Proto": {"http"},
+				"Forwarded":         {`for="[2001:db8::1]";host=example.com;proto=http`},
+			},
+		},
+	} {
+		h := http.Header{"Forwarded": {"for=6.6.6.6"}}
+		tc.policy.Resolve(newRequest(tc.remoteAddr, headers)).SetHeaders(h)
+		assert.Equal(t, tc.want, h, tc.name)
+	}
+}
+
+// Test hops that are not addresses are passed on as unknown
+func TestSetHeaders_UnknownHop(t *testing.T) {
+	p := newPolicy(t, ModeAppend, true, "10.0.0.0/8")
+	req := newRequest("10.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.1.1.1, <script>"})
+
+	h := http.Header{}
+	p.Resolve(req).SetHeaders(h)
+	assert.Equal(t, "1.1.1.1, unknown, 10.0.0.1", h.Get("X-Forwarded-For"))
+	assert.Equal(t, []string{"for=1.1.1.1", "for=unknown", "for=10.0.0.1;host=example.com;proto=http"}, h.Values("Forwarded"))
+
+	h = http.Header{}
+	newPolicy(t, ModeOverwrite, false, "10.0.0.0/8").Resolve(req).SetHeaders(h)
+	assert.Equal(t, "10.0.0.1", h.Get("X-Forwarded-For"))
+}
+
+// Test trusted Forwarded elements are passed on as received in append mode
+func TestSetHeaders_AppendForwarded(t *testing.T) {
+	p := newPolicy(t, ModeAppend, true, "10.0.0.0/8")
+	req := newRequest("10.0.0.1:1234", map[string]string{"Forwarded": `for="[2001:db9::7]:4711";proto=https;by=10.0.0.1`})
+
+	h := http.Header{}
+	p.Resolve(req).SetHeaders(h)
+	assert.Equal(t, []string{`for="[2001:db9::7]:4711";proto=https;by=10.0.0.1`, "for=10.0.0.1;host=example.com;proto=http"}, h.Values("Forwarded"))
+	assert.Equal(t, "2001:db9::7, 10.0.0.1", h.Get("X-Forwarded-For"))
+	assert.Equal(t, "https", h.Get("X-Forwarded-Proto"))
+}
+
+// Test ClientIP reads the resolved client of the context, the remote address otherwise
+func TestClientIP(t *testing.T) {
+	req := newRequest("203.0.113.9:1234", nil)
+	assert.Equal(t, "203.0.113.9", ClientIP(req))
+
+	info := newPolicy(t, ModeAppend, false, "203.0.113.0/24").Resolve(newRequest("203.0.113.9:1234", map[string]string{"X-Forwarded-For": "198.51.100.4"}))
+	req = req.WithContext(NewContext(context.Background(), info))
+	assert.Equal(t, "198.51.100.4", ClientIP(req))
+}
@@ -0,0 +1,145 @@
+package forwarded
+
+import "strings"
+
+// element is a Forwarded element, the parameters a single proxy added.
+type element struct {
+	node  string // for=
+	host  string // host=
+	proto string // proto=
+}
+
+// parseForwarded parses Forwarded header values into their elements, see RFC 7239 section 4.
+// Unknown parameters are skipped. Returns false if a value is malformed.
+func parseForwarded(values []string) ([]element, bool) {
+	var elements []element
+	for _, s := range values {
+		for {
+			s = trimOWS(s)
+			if s == "" {
+				break
+			}
+			if s[0] == ',' { // empty list element
+				s = s[1:]
+				continue
+			}
+
+			var e element
+			for {
+				key, rest, ok := cutToken(trimOWS(s))
+				if !ok || !strings.HasPrefix(rest, "=") {
+					return nil, false
+				}
+				value, rest, ok := cutValue(rest[1:])
+				if !ok {
+					return nil, false
+				}
+				switch strings.ToLower(key) {
+				case "for":
+					e.node = value
+				case "host":
+					e.host = value
+				case "proto":
+					e.proto = value
+				}
+
+				s = trimOWS(rest)
+				if !strings.HasPrefix(s, ";") {
+					break
+				}
+				s = s[1:]
+			}
+			elements = append(elements, e)
+
+			if s != "" && s[0] != ',' {
+				return nil, false
+			}
+		}
+	}
+	return elements, true
+}
+
+// formatElement returns the Forwarded element of a hop.
+func formatElement(node, host, proto string) string {
+	e := "for=" + formatNode(node)
+	if host != "" {
+		e += ";host=" + quote(host)
+	}
+	return e + ";proto=" + proto
+}
+
+// formatNode returns the for= value of an address. IPv6 addresses are bracketed, which requires quoting.
+func formatNode(node string) string {
+	addr, ok := parseNode(node)
+	switch {
+	case !ok:
+		return quote(node)
+	case addr.Is6():
+		return `"[` + addr.String() + `]"`
+	default:
+		return addr.String()
+	}
+}
+
+// quote returns s as a token if it is one, as a quoted string otherwise.
+func quote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool { return r > 0x7f || !isTokenChar(byte(r)) }) < 0 {
+		return s
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// cutToken splits the leading token off s.
+func cutToken(s string) (token, rest string, ok bool) {
+	i := 0
+	for i < len(s) && isTokenChar(s[i]) {
+		i++
+	}
+	return s[:i], s[i:], i > 0
+}
+
+// cutValue splits the leading token or quoted string off s, the quoted string unescaped.
+func cutValue(s string) (value, rest string, ok bool) {
+	if !strings.HasPrefix(s, `"`) {
+		return cutToken(s)
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return b.String(), s[i+1:], true
+		case '\\':
+			i++
+			if i == len(s) {
+				return "", "", false
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return "", "", false // unterminated
+}
+
+func trimOWS(s string) string {
+	return strings.TrimLeft(s, " \t")
+}
+
+// isTokenChar reports whether c is a tchar of RFC 9110.
+func isTokenChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	default:
+		return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+	}
+}
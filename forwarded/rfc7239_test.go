@@ -0,0 +1,36 @@
+package forwarded
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test Forwarded values are split into elements, with quoted strings, case-insensitive and unknown parameters
+func TestParseForwarded(t *testing.T) {
+	elements, ok := parseForwarded([]string{
+		`for=192.0.2.60;proto=http;by=203.0.113.43`,
+		`For="[2001:db8:cafe::17]:4711" , , for=unknown;host="a\"b"`,
+	})
+	assert.True(t, ok)
+	assert.Equal(t, []element{
+		{node: "192.0.2.60", proto: "http"},
+		{node: "[2001:db8:cafe::17]:4711"},
+		{node: "unknown", host: `a"b`},
+	}, elements)
+
+	for _, malformed := range []string{`for`, `for=`, `for="open`, `for=a b`, `for=a;`, `=a`, `for="a\`} {
+		_, ok := parseForwarded([]string{malformed})
+		assert.False(t, ok, malformed)
+	}
+}
+
+// Test nodes and hosts are quoted when they are not tokens
+func TestFormatElement(t *testing.T) {
+	assert.Equal(t, "for=192.0.2.60;host=example.com;proto=https", formatElement("192.0.2.60", "example.com", "https"))
+	assert.Equal(t, `for="[2001:db8::17]";host="example.com:8080";proto=http`, formatElement("2001:db8::17", "example.com:8080", "http"))
+	assert.Equal(t, `for=192.0.2.60;proto=http`, formatElement("::ffff:192.0.2.60", "", "http"))
+	assert.Equal(t, `_hidden`, formatNode("_hidden"))
+	assert.Equal(t, `"[2001:db8::17]"`, formatNode("[2001:db8::17]:4711"))
+	assert.Equal(t, `"a \"b\""`, quote(`a "b"`))
+}
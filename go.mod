@@ -1,9 +1,12 @@
 module load-balancer
 
-go 1.22.2
+// go 1.24 for http.Protocols: the gRPC health check speaks HTTP/2 with prior knowledge (h2c)
+// through the standard library, so golang.org/x/net/http2 is not needed
+go 1.24
 
 require (
 	github.com/stretchr/testify v1.11.1
+	go.uber.org/zap v1.27.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
@@ -11,5 +14,4 @@ require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
-	go.uber.org/zap v1.27.0 // indirect
 )
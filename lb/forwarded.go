@@ -0,0 +1,17 @@
+package lb
+
+import (
+	"load-balancer/forwarded"
+	"net/http"
+)
+
+// withForwarded resolves the client, scheme and host of the request with the forwarded policy.
+// The returned request carries them in its context, the backend sets the forwarding headers from there.
+func (lb *loadBalancer) withForwarded(r *http.Request) *http.Request {
+	info := lb.forwarded.Resolve(r)
+	if reqInfo, ok := getRequestInfo(r); ok {
+		reqInfo.ClientIP = info.ClientIP
+	}
+
+	return r.WithContext(forwarded.NewContext(r.Context(), info))
+}
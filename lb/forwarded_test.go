@@ -0,0 +1,50 @@
+package lb
+
+import (
+	"load-balancer/backend"
+	"load-balancer/forwarded"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test the client is resolved through trusted proxies for the pool, the access log and the backend headers
+func TestForwarded(t *testing.T) {
+	var received http.Header
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err, "failed to parse url")
+
+	pool := &recordingPool{peer: backend.NewBackend(u)}
+	l := NewLoadBalancerWithOptions(pool, Options{Forwarded: forwarded.Policy{
+		Mode:           forwarded.ModeOverwrite,
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+	}})
+
+	for _, tc := range []struct {
+		remoteAddr string
+		client     string
+	}{
+		{"10.0.0.1:1234", "198.51.100.4"},   // trusted proxy
+		{"203.0.113.9:1234", "203.0.113.9"}, // spoofing client
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tc.remoteAddr
+		req.Header.Set("X-Forwarded-For", "1.1.1.1, 198.51.100.4")
+		req, info := WithRequestInfo(req)
+		l.ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Equal(t, tc.client, info.ClientIP, tc.remoteAddr)
+		assert.Equal(t, tc.client, forwarded.ClientIP(pool.seen), tc.remoteAddr)
+		assert.Equal(t, tc.client, received.Get("X-Forwarded-For"), tc.remoteAddr)
+	}
+}
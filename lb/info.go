@@ -0,0 +1,36 @@
+package lb
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+const requestInfoKey contextKey = "request_info"
+
+// RequestInfo records how the load balancer served a request, for access logs.
+// It is filled in by ServeHTTP when attached to the request with WithRequestInfo.
+type RequestInfo struct {
+	RequestID        string        // X-Request-ID of the request
+	ClientIP         string        // client address, resolved through trusted proxies
+	Backend          string        // url of the backend of the last attempt, empty if none was available
+	Attempts         int           // backends the request was sent to
+	UpstreamDuration time.Duration // time spent in the last attempt, until the response was copied
+}
+
+// Retries returns the number of attempts after the first.
+func (i *RequestInfo) Retries() int {
+	return max(i.Attempts-1, 0)
+}
+
+// WithRequestInfo attaches an empty RequestInfo to the request, read back once ServeHTTP returned.
+func WithRequestInfo(r *http.Request) (*http.Request, *RequestInfo) {
+	info := &RequestInfo{}
+	return r.WithContext(context.WithValue(r.Context(), requestInfoKey, info)), info
+}
+
+// getRequestInfo returns the RequestInfo attached to the request.
+func getRequestInfo(r *http.Request) (*RequestInfo, bool) {
+	info, ok := r.Context().Value(requestInfoKey).(*RequestInfo)
+	return info, ok
+}
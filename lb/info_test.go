@@ -0,0 +1,38 @@
+package lb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test the request info names the backend of the last attempt and counts the retries
+func TestRequestInfo_Retries(t *testing.T) {
+	f := newRetryFixture(t, 2, 1, Options{MaxAttempts: 3})
+	healthy := f.sp.GetBackends()[2].GetURL().String()
+
+	req, info := WithRequestInfo(httptest.NewRequest(http.MethodGet, "/", nil))
+	rr := httptest.NewRecorder()
+	f.lb.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, healthy, info.Backend)
+	assert.Equal(t, 3, info.Attempts)
+	assert.Equal(t, 2, info.Retries())
+	assert.Positive(t, info.UpstreamDuration)
+}
+
+// Test the request info stays empty when no backend is available
+func TestRequestInfo_NoBackend(t *testing.T) {
+	f := newRetryFixture(t, 0, 0, Options{})
+
+	req, info := WithRequestInfo(httptest.NewRequest(http.MethodGet, "/", nil))
+	rr := httptest.NewRecorder()
+	f.lb.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Empty(t, info.Backend)
+	assert.Equal(t, 0, info.Retries())
+}
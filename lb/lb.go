@@ -1,61 +1,159 @@
 package lb
 
 import (
+	"errors"
 	"fmt"
+	"load-balancer/backend"
+	"load-balancer/forwarded"
 	"load-balancer/serverpool"
+	"load-balancer/tracing"
 	"load-balancer/utils"
 	"net/http"
+	"time"
 )
 
 // Use contextKey for type safe context values.
 type contextKey string
 
-const RetryAttemptedKey contextKey = "retry_attempted"
+const RetryStateKey contextKey = "retry_state"
 
-// AllowRetry checks if the RetryAttemptedKey is in the request context.
-// Returns true (retry is allowed) if it is not in the context.
-func AllowRetry(r *http.Request) bool {
-	if _, ok := r.Context().Value(RetryAttemptedKey).(bool); ok {
-		return false
-	}
-	return true
-}
+// errNoBackend is recorded on the request span when no backend was available.
+var errNoBackend = errors.New("no backend available")
 
 // LoadBalancer interface wraos a server pool for handling HTTP requests.
 type LoadBalancer interface {
 	http.Handler
 }
 
+// Options configures a load balancer.
+type Options struct {
+	MaxAttempts   int // backends tried per request, including the first (default utils.MAX_LB_ATTEMPTS)
+	Retry         RetryOptions
+	StickySession StickySessionOptions
+	Tracer        *tracing.Tracer  // records a server span per request, nil disables tracing
+	Forwarded     forwarded.Policy // client resolution and forwarding headers sent to backends
+}
+
 // loadBalancer implements LoadBalancer by delegating requests to a server pool.
 type loadBalancer struct {
-	sp serverpool.ServerPool
+	sp          serverpool.ServerPool
+	maxAttempts int
+	retry       RetryOptions
+	sticky      *stickySession  // nil when sticky sessions are disabled
+	tracer      *tracing.Tracer // nil when tracing is disabled
+	forwarded   forwarded.Policy
 }
 
 // ServeHTTP selects the next available backend server from the server pool and forwards the request.
+// With sticky sessions enabled, the backend named by the session cookie is used while it is alive,
+// otherwise the pool strategy picks a backend and the cookie is (re)issued.
+// When called again for a retry, backends already tried for the request are excluded.
+// The client is resolved once through the trusted proxies of the forwarded policy, for hashing, logs and forwarding headers.
+// Every request gets an X-Request-ID, kept from the client when valid, forwarded to the backend and echoed on the response.
 // If there is no backend available, it responds with "service unavailable".
 func (lb *loadBalancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// pick the next server to serve
-	peer := lb.sp.GetNextValidPeer()
+	state, ok := getRetryState(r)
+	if ok {
+		// Retry from the original request, not the one rewritten for the failed backend
+		r = state.request
+	} else {
+		r = lb.withForwarded(r)
+		r = withRequestID(w, r)
+
+		if lb.tracer != nil {
+			// The span covers every attempt, retries return before the first call does
+			var span *tracing.Span
+			r, span = lb.startRequestSpan(r)
+			defer func() { endRequestSpan(span, state) }()
+		}
+
+		var err error
+		r, state, err = withRetryState(r, lb.maxAttempts, lb.retry)
+		if err != nil {
+			http.Error(w, "failed to read request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var peer backend.Backend
+	if lb.sticky != nil {
+		peer = lb.sticky.peer(r, lb.sp)
+	}
+
 	if peer == nil {
-		http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+		// pick the next server to serve
+		peer = lb.sp.GetNextValidPeer(r)
+		if peer == nil {
+			if state.attempts > 0 {
+				http.Error(w, fmt.Sprintf("service unavailable after %d attempts", state.attempts), http.StatusServiceUnavailable)
+				return
+			}
+			http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		if lb.sticky != nil {
+			lb.sticky.setCookie(w, peer)
+		}
+	}
+
+	state.attempts++
+	state.tried[peer.GetURL().String()] = struct{}{}
+	state.rewindBody()
+
+	if lb.tracer != nil {
+		// Tell the attempt span of the backend which retry this is
+		r = r.WithContext(backend.WithAttemptNumber(r.Context(), state.attempts))
+	}
+
+	info, ok := getRequestInfo(r)
+	if !ok {
+		peer.ServeHTTP(w, r)
 		return
 	}
+
+	info.Backend = peer.GetURL().String()
+	info.Attempts = state.attempts
+	attempt := state.attempts
+
+	start := time.Now()
 	peer.ServeHTTP(w, r)
+
+	// A failed attempt returns after the retries it triggered, keep the duration of the last one
+	if info.Attempts == attempt {
+		info.UpstreamDuration = time.Since(start)
+	}
 }
 
 // NewLoadBalancer constructs a load balancer with provided server pool.
 // If the server pool is nil, then NewLoadBalancer will create a new server pool using round-robin strategy.
 func NewLoadBalancer(sp serverpool.ServerPool) LoadBalancer {
+	return NewLoadBalancerWithOptions(sp, Options{})
+}
+
+// NewLoadBalancerWithOptions constructs a load balancer with provided server pool and options.
+// If the server pool is nil, a new server pool using round-robin strategy is created.
+func NewLoadBalancerWithOptions(sp serverpool.ServerPool, opts Options) LoadBalancer {
 	if sp == nil {
 		pool, err := serverpool.NewServerPool(utils.GetLBStrategy("round-robin"))
 		if err != nil {
 			fmt.Printf("%s\n", err)
 			return nil
 		}
-		return &loadBalancer{sp: pool}
+		sp = pool
+	}
+
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = utils.MAX_LB_ATTEMPTS
 	}
 
 	return &loadBalancer{
-		sp: sp,
+		sp:          sp,
+		maxAttempts: maxAttempts,
+		retry:       opts.Retry,
+		sticky:      newStickySession(opts.StickySession),
+		tracer:      opts.Tracer,
+		forwarded:   opts.Forwarded,
 	}
 }
@@ -0,0 +1,97 @@
+package lb
+
+import (
+	"load-balancer/backend"
+	"load-balancer/serverpool"
+	"load-balancer/utils"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingPool is a ServerPool that records the request passed to GetNextValidPeer.
+type recordingPool struct {
+	peer backend.Backend
+	seen *http.Request
+}
+
+func (p *recordingPool) GetBackends() []backend.Backend          { return []backend.Backend{p.peer} }
+func (p *recordingPool) AddBackend(backend.Backend)              {}
+func (p *recordingPool) RemoveBackend(string) bool               { return false }
+func (p *recordingPool) RemoveBackendIf(backend.Backend) bool    { return false }
+func (p *recordingPool) DrainBackend(string, time.Duration) bool { return false }
+func (p *recordingPool) GetServerPoolSize() int                  { return 1 }
+func (p *recordingPool) GetNextValidPeer(r *http.Request) backend.Backend {
+	p.seen = r
+	return p.peer
+}
+
+// Test the incoming request is handed to the server pool for peer selection
+func TestServeHTTP_PassesRequestToPool(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err, "failed to parse url")
+
+	pool := &recordingPool{peer: backend.NewBackend(u)}
+	lb := NewLoadBalancer(pool)
+
+	req := httptest.NewRequest(http.MethodGet, "/cart", nil)
+	req.Header.Set("X-User", "alice")
+	rr := httptest.NewRecorder()
+
+	lb.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	require.NotNil(t, pool.seen)
+	assert.Equal(t, "/cart", pool.seen.URL.Path)
+	assert.Equal(t, "alice", pool.seen.Header.Get("X-User"))
+}
+
+// Test request-aware strategy routes the same key to the same backend through the load balancer
+func TestServeHTTP_ConsistentHashRouting(t *testing.T) {
+	sp, err := serverpool.NewServerPoolWithOptions(serverpool.Options{
+		Strategy: utils.ConsistentHash,
+		HashKey:  "header:X-User",
+	})
+	require.NoError(t, err, "failed to create server pool")
+
+	hits := map[string]int{}
+	for i := 0; i < 3; i++ {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits[r.Host]++
+		}))
+		defer s.Close()
+
+		u, err := url.Parse(s.URL)
+		require.NoError(t, err, "failed to parse url")
+		sp.AddBackend(backend.NewBackend(u))
+	}
+
+	lb := NewLoadBalancer(sp)
+	for i := 0; i < 10; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-User", "alice")
+		lb.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	assert.Len(t, hits, 1)
+}
+
+// Test no alive backend results in service unavailable
+func TestServeHTTP_NoPeer(t *testing.T) {
+	lb := NewLoadBalancer(&recordingPool{})
+
+	rr := httptest.NewRecorder()
+	lb.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+}
@@ -0,0 +1,23 @@
+package lb
+
+import (
+	"load-balancer/requestid"
+	"net/http"
+)
+
+// withRequestID keeps the X-Request-ID of the request if it is valid, generates one otherwise,
+// and echoes it on the response. The returned request carries the id in its context,
+// the backend forwards it from there.
+func withRequestID(w http.ResponseWriter, r *http.Request) *http.Request {
+	id := r.Header.Get(requestid.Header)
+	if !requestid.Valid(id) {
+		id = requestid.New()
+	}
+
+	w.Header().Set(requestid.Header, id)
+	if info, ok := getRequestInfo(r); ok {
+		info.RequestID = id
+	}
+
+	return r.WithContext(requestid.NewContext(r.Context(), id))
+}
@@ -0,0 +1,67 @@
+package lb
+
+import (
+	"load-balancer/backend"
+	"load-balancer/requestid"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test a valid incoming id is kept, forwarded and echoed once, and an invalid one is replaced
+func TestRequestID(t *testing.T) {
+	var received string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(requestid.Header)
+		w.Header().Set(requestid.Header, "backend-id")
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err, "failed to parse url")
+	l := NewLoadBalancer(&recordingPool{peer: backend.NewBackend(u)})
+
+	for _, tc := range []struct {
+		incoming string
+		keep     bool
+	}{
+		{"req-42", true},
+		{"", false},
+		{"has space", false},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.incoming != "" {
+			req.Header.Set(requestid.Header, tc.incoming)
+		}
+		rr := httptest.NewRecorder()
+		l.ServeHTTP(rr, req)
+
+		echoed := rr.Header().Values(requestid.Header)
+		require.Len(t, echoed, 1, tc.incoming)
+		assert.Equal(t, echoed[0], received, tc.incoming)
+		assert.True(t, requestid.Valid(received), tc.incoming)
+		if tc.keep {
+			assert.Equal(t, tc.incoming, received)
+		} else {
+			assert.NotEqual(t, tc.incoming, received)
+		}
+	}
+}
+
+// Test every attempt of a request carries the same id, and the id is echoed when no backend is left
+func TestRequestID_Retries(t *testing.T) {
+	f := newRetryFixture(t, 2, 0, Options{MaxAttempts: 3})
+
+	req, info := WithRequestInfo(httptest.NewRequest(http.MethodGet, "/", nil))
+	rr := httptest.NewRecorder()
+	f.lb.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, 2, info.Attempts)
+	assert.NotEmpty(t, info.RequestID)
+	assert.Equal(t, []string{info.RequestID}, rr.Header().Values(requestid.Header))
+}
@@ -0,0 +1,119 @@
+package lb
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"load-balancer/backend"
+	"load-balancer/serverpool"
+	"net/http"
+)
+
+// defaultMaxRetryBodySize is the default number of body bytes buffered for replay.
+const defaultMaxRetryBodySize int64 = 1 << 20
+
+// RetryOptions configures which requests may be retried on another backend.
+type RetryOptions struct {
+	MaxBodySize    int64 // bytes buffered for replay, larger bodies are not retried (default 1 MiB)
+	IdempotentOnly bool  // skip retries for non-idempotent methods
+}
+
+// retryState tracks the attempts made for a single client request.
+// It is stored once in the request context, so it is shared by every retry of the request.
+type retryState struct {
+	request     *http.Request       // original client request, every attempt starts from it
+	body        []byte              // buffered request body, replayed on every attempt
+	attempts    int                 // number of backends the request was sent to
+	maxAttempts int                 // attempt limit
+	noRetry     bool                // the request cannot be replayed safely
+	tried       map[string]struct{} // urls of the backends already tried
+}
+
+// getRetryState returns the retry state of the request.
+func getRetryState(r *http.Request) (*retryState, bool) {
+	state, ok := r.Context().Value(RetryStateKey).(*retryState)
+	return state, ok
+}
+
+// isIdempotent reports whether the method is idempotent (RFC 9110 section 9.2.2).
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// withRetryState attaches a new retry state to the request and excludes tried backends from selection.
+// The request body is buffered up to opts.MaxBodySize so it can be replayed on retries. Requests with
+// larger bodies, or non-idempotent requests when opts.IdempotentOnly is set, are sent once.
+func withRetryState(r *http.Request, maxAttempts int, opts RetryOptions) (*http.Request, *retryState, error) {
+	state := &retryState{
+		maxAttempts: maxAttempts,
+		tried:       make(map[string]struct{}),
+	}
+
+	ctx := context.WithValue(r.Context(), RetryStateKey, state)
+	ctx = serverpool.WithExcludedPeers(ctx, state.tried)
+	state.request = r.WithContext(ctx)
+
+	if opts.IdempotentOnly && !isIdempotent(r.Method) {
+		state.noRetry = true
+	}
+
+	if !state.noRetry && r.Body != nil && r.Body != http.NoBody {
+		limit := opts.MaxBodySize
+		if limit <= 0 {
+			limit = defaultMaxRetryBodySize
+		}
+
+		buf, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if int64(len(buf)) > limit {
+			// Too large to replay, stream the rest of the body after the buffered part
+			state.noRetry = true
+			state.request.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+		} else {
+			state.body = buf
+			state.request.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(state.body)), nil
+			}
+		}
+	}
+
+	return state.request, state, nil
+}
+
+// rewindBody resets the request body to the buffered body before an attempt.
+func (s *retryState) rewindBody() {
+	if s.body != nil {
+		s.request.Body, _ = s.request.GetBody()
+	}
+}
+
+// AllowRetry checks if the request may be retried on another backend.
+// Returns true while the number of attempts is below the configured limit, the request can be replayed
+// and the client is still waiting.
+func AllowRetry(r *http.Request) bool {
+	state, ok := getRetryState(r)
+	if !ok || state.noRetry || backend.ClientGone(r) {
+		return false
+	}
+	return state.attempts < state.maxAttempts
+}
+
+// GetAttempts returns the number of backends the request was sent to.
+func GetAttempts(r *http.Request) int {
+	state, ok := getRetryState(r)
+	if !ok {
+		return 0
+	}
+	return state.attempts
+}
@@ -0,0 +1,245 @@
+package lb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"load-balancer/backend"
+	"load-balancer/serverpool"
+	"load-balancer/utils"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// retryFixture is a load balancer whose backends retry through it on error, like main.go.
+type retryFixture struct {
+	lb   LoadBalancer
+	sp   serverpool.ServerPool
+	mux  sync.Mutex
+	hits map[string]int // attempts per backend url
+}
+
+func (f *retryFixture) hit(u string) {
+	f.mux.Lock()
+	f.hits[u]++
+	f.mux.Unlock()
+}
+
+// newRetryFixture creates a load balancer over dead backends followed by healthy ones.
+// Least connections picks idle backends in order, so the dead backends are always tried first.
+// Healthy backends echo the request body.
+func newRetryFixture(t *testing.T, dead, healthy int, opts Options) *retryFixture {
+	t.Helper()
+
+	sp, err := serverpool.NewServerPool(utils.LeastConnected)
+	require.NoError(t, err, "failed to create server pool")
+
+	f := &retryFixture{sp: sp, hits: map[string]int{}}
+	f.lb = NewLoadBalancerWithOptions(sp, opts)
+
+	add := func(u *url.URL) {
+		b := backend.NewBackend(u)
+		b.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, e error) {
+			f.hit(u.String())
+			if !AllowRetry(r) {
+				http.Error(w, fmt.Sprintf("service not available after %d attempts", GetAttempts(r)), http.StatusServiceUnavailable)
+				return
+			}
+			f.lb.ServeHTTP(w, r)
+		})
+		sp.AddBackend(b)
+	}
+
+	for i := 0; i < dead; i++ {
+		// Closed server: connections are refused
+		s := httptest.NewServer(http.NotFoundHandler())
+		s.Close()
+		u, err := url.Parse(s.URL)
+		require.NoError(t, err, "failed to parse url")
+		add(u)
+	}
+
+	for i := 0; i < healthy; i++ {
+		var u *url.URL
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			f.hit(u.String())
+			body, _ := io.ReadAll(r.Body)
+			_, _ = w.Write([]byte("ok"))
+			_, _ = w.Write(body)
+		}))
+		t.Cleanup(s.Close)
+		u, err = url.Parse(s.URL)
+		require.NoError(t, err, "failed to parse url")
+		add(u)
+	}
+
+	return f
+}
+
+// Test the request is retried on different backends until one succeeds
+func TestRetry_FailsOverToHealthyBackend(t *testing.T) {
+	f := newRetryFixture(t, 3, 1, Options{MaxAttempts: 4})
+
+	rr := httptest.NewRecorder()
+	f.lb.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "ok", rr.Body.String())
+
+	// No backend is tried twice for the same request
+	for u, n := range f.hits {
+		assert.Equal(t, 1, n, u)
+	}
+}
+
+// Test retries stop at the attempt limit and report the number of attempts
+func TestRetry_AttemptLimit(t *testing.T) {
+	f := newRetryFixture(t, 4, 0, Options{MaxAttempts: 3})
+
+	rr := httptest.NewRecorder()
+	f.lb.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Contains(t, rr.Body.String(), "after 3 attempts")
+
+	total := 0
+	for u, n := range f.hits {
+		assert.Equal(t, 1, n, u)
+		total += n
+	}
+	assert.Equal(t, 3, total)
+}
+
+// Test retries stop early when every backend was tried
+func TestRetry_AllBackendsTried(t *testing.T) {
+	f := newRetryFixture(t, 2, 0, Options{MaxAttempts: 5})
+
+	rr := httptest.NewRecorder()
+	f.lb.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Contains(t, rr.Body.String(), "after 2 attempts")
+	assert.Len(t, f.hits, 2)
+}
+
+// Test a POST body is replayed in full on the retried backend
+func TestRetry_ReplaysBody(t *testing.T) {
+	f := newRetryFixture(t, 2, 1, Options{MaxAttempts: 3})
+
+	body := strings.Repeat("payload-", 1000)
+	rr := httptest.NewRecorder()
+	f.lb.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "ok"+body, rr.Body.String())
+	assert.Len(t, f.hits, 3)
+}
+
+// Test bodies over the buffer limit are sent once without retries
+func TestRetry_BodyOverLimit(t *testing.T) {
+	f := newRetryFixture(t, 1, 1, Options{MaxAttempts: 3, Retry: RetryOptions{MaxBodySize: 16}})
+
+	rr := httptest.NewRecorder()
+	f.lb.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("x", 17))))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Contains(t, rr.Body.String(), "after 1 attempts")
+
+	// A body within the limit is retried
+	rr = httptest.NewRecorder()
+	f.lb.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("x", 16))))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "ok"+strings.Repeat("x", 16), rr.Body.String())
+}
+
+// Test a body over the limit still reaches the backend in full
+func TestRetry_BodyOverLimitForwarded(t *testing.T) {
+	f := newRetryFixture(t, 0, 1, Options{Retry: RetryOptions{MaxBodySize: 16}})
+
+	body := strings.Repeat("y", 100)
+	rr := httptest.NewRecorder()
+	f.lb.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "ok"+body, rr.Body.String())
+}
+
+// Test non-idempotent requests are not retried when configured
+func TestRetry_IdempotentOnly(t *testing.T) {
+	f := newRetryFixture(t, 1, 1, Options{MaxAttempts: 3, Retry: RetryOptions{IdempotentOnly: true}})
+
+	rr := httptest.NewRecorder()
+	f.lb.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("data")))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Contains(t, rr.Body.String(), "after 1 attempts")
+
+	// PUT is idempotent and may be retried
+	rr = httptest.NewRecorder()
+	f.lb.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/", strings.NewReader("data")))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "okdata", rr.Body.String())
+}
+
+// Test a retryable upstream status moves the request to the next backend
+func TestRetry_RetryableStatus(t *testing.T) {
+	sp, err := serverpool.NewServerPool(utils.LeastConnected)
+	require.NoError(t, err, "failed to create server pool")
+	lb := NewLoadBalancer(sp)
+
+	for _, status := range []int{http.StatusServiceUnavailable, http.StatusOK} {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			w.WriteHeader(status)
+			_, _ = w.Write(body)
+		}))
+		t.Cleanup(s.Close)
+
+		u, err := url.Parse(s.URL)
+		require.NoError(t, err, "failed to parse url")
+		b := backend.NewBackend(u)
+		b.SetRetryPolicy(backend.RetryPolicy{Statuses: []int{503}, AllowRetry: AllowRetry})
+		b.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, e error) {
+			lb.ServeHTTP(w, r)
+		})
+		sp.AddBackend(b)
+	}
+
+	rr := httptest.NewRecorder()
+	lb.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/", strings.NewReader("data")))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "data", rr.Body.String())
+}
+
+// Test a request is not retried once the client gave up
+func TestRetry_ClientGone(t *testing.T) {
+	f := newRetryFixture(t, 3, 1, Options{MaxAttempts: 4})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	f.lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+	attempts := 0
+	for _, n := range f.hits {
+		attempts += n
+	}
+	assert.Equal(t, 1, attempts)
+}
+
+// Test the attempt counter without a load balancer in the request path
+func TestRetry_NoState(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	assert.False(t, AllowRetry(req))
+	assert.Equal(t, 0, GetAttempts(req))
+}
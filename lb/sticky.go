@@ -0,0 +1,145 @@
+package lb
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"load-balancer/backend"
+	"load-balancer/serverpool"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StickySessionOptions configures cookie based session affinity.
+type StickySessionOptions struct {
+	Enabled    bool
+	CookieName string
+	TTL        time.Duration
+	SigningKey []byte // HMAC-SHA256 key, a random key is generated when empty
+}
+
+// stickySession pins clients to a backend with a signed cookie.
+// The cookie value is "<base64 url>.<unix expiry>.<base64 signature>".
+type stickySession struct {
+	cookieName string
+	ttl        time.Duration
+	key        []byte
+}
+
+// newStickySession creates a sticky session handler, or returns nil if sticky sessions are disabled.
+func newStickySession(opts StickySessionOptions) *stickySession {
+	if !opts.Enabled {
+		return nil
+	}
+
+	key := opts.SigningKey
+	if len(key) == 0 {
+		// Cookies will not survive a restart, but cannot be forged either
+		key = make([]byte, 32)
+		_, _ = rand.Read(key)
+	}
+
+	name := opts.CookieName
+	if name == "" {
+		name = "lb_backend"
+	}
+
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+
+	return &stickySession{cookieName: name, ttl: ttl, key: key}
+}
+
+// sign returns the base64 HMAC of the payload.
+func (s *stickySession) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// cookie builds a signed cookie naming the backend.
+func (s *stickySession) cookie(b backend.Backend) *http.Cookie {
+	expires := time.Now().Add(s.ttl)
+	payload := base64.RawURLEncoding.EncodeToString([]byte(b.GetURL().String())) + "." + strconv.FormatInt(expires.Unix(), 10)
+
+	return &http.Cookie{
+		Name:     s.cookieName,
+		Value:    payload + "." + s.sign(payload),
+		Path:     "/",
+		Expires:  expires,
+		MaxAge:   int(s.ttl.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// backendURL returns the backend URL stored in the request cookie.
+// Returns false if the cookie is missing, expired or its signature does not match.
+func (s *stickySession) backendURL(r *http.Request) (string, bool) {
+	c, err := r.Cookie(s.cookieName)
+	if err != nil {
+		return "", false
+	}
+
+	i := strings.LastIndexByte(c.Value, '.')
+	if i < 0 {
+		return "", false
+	}
+	payload, signature := c.Value[:i], c.Value[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(s.sign(payload))) {
+		return "", false
+	}
+
+	encodedURL, expiry, ok := strings.Cut(payload, ".")
+	if !ok {
+		return "", false
+	}
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return "", false
+	}
+
+	u, err := base64.RawURLEncoding.DecodeString(encodedURL)
+	if err != nil {
+		return "", false
+	}
+	return string(u), true
+}
+
+// peer returns the alive backend named by the request cookie, or nil if there is none.
+func (s *stickySession) peer(r *http.Request, sp serverpool.ServerPool) backend.Backend {
+	u, ok := s.backendURL(r)
+	if !ok {
+		return nil
+	}
+
+	for _, b := range sp.GetBackends() {
+		if b.GetURL().String() == u {
+			if serverpool.IsSelectable(r, b) {
+				return b
+			}
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// setCookie sets the sticky cookie on the response, replacing one set by a previous attempt of the same request.
+func (s *stickySession) setCookie(w http.ResponseWriter, b backend.Backend) {
+	prefix := s.cookieName + "="
+	cookies := w.Header().Values("Set-Cookie")
+	w.Header().Del("Set-Cookie")
+	for _, v := range cookies {
+		if !strings.HasPrefix(v, prefix) {
+			w.Header().Add("Set-Cookie", v)
+		}
+	}
+
+	http.SetCookie(w, s.cookie(b))
+}
@@ -0,0 +1,178 @@
+package lb
+
+import (
+	"load-balancer/backend"
+	"load-balancer/serverpool"
+	"load-balancer/utils"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stickyOptions enables sticky sessions in the tests.
+var stickyOptions = StickySessionOptions{
+	Enabled:    true,
+	CookieName: "sticky",
+	TTL:        time.Minute,
+	SigningKey: []byte("secret"),
+}
+
+func stickyCookie(t *testing.T, rr *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == "sticky" {
+			return c
+		}
+	}
+	return nil
+}
+
+// Test the first response sets a cookie and later requests stay on the same backend
+func TestSticky_PinsBackend(t *testing.T) {
+	sp, err := serverpool.NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	// Each server answers with its own URL in the body
+	for i := 0; i < 3; i++ {
+		s := httptest.NewServer(nil)
+		s.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(s.URL))
+		})
+		defer s.Close()
+
+		u, err := url.Parse(s.URL)
+		require.NoError(t, err, "failed to parse url")
+		sp.AddBackend(backend.NewBackend(u))
+	}
+	lb := NewLoadBalancerWithOptions(sp, Options{StickySession: stickyOptions})
+
+	rr := httptest.NewRecorder()
+	lb.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	first := rr.Body.String()
+
+	cookie := stickyCookie(t, rr)
+	require.NotNil(t, cookie)
+
+	for i := 0; i < 6; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookie)
+		rr := httptest.NewRecorder()
+		lb.ServeHTTP(rr, req)
+
+		assert.Equal(t, first, rr.Body.String())
+		assert.Nil(t, stickyCookie(t, rr), "cookie should only be issued once")
+	}
+}
+
+// Test a dead pinned backend falls back to the strategy and reissues the cookie
+func TestSticky_DeadBackendFallback(t *testing.T) {
+	sp, err := serverpool.NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	// Each server answers with its own URL in the body
+	for i := 0; i < 2; i++ {
+		s := httptest.NewServer(nil)
+		s.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(s.URL))
+		})
+		defer s.Close()
+
+		u, err := url.Parse(s.URL)
+		require.NoError(t, err, "failed to parse url")
+		sp.AddBackend(backend.NewBackend(u))
+	}
+	lb := NewLoadBalancerWithOptions(sp, Options{StickySession: stickyOptions})
+	backends := sp.GetBackends()
+
+	rr := httptest.NewRecorder()
+	lb.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	first := rr.Body.String()
+	cookie := stickyCookie(t, rr)
+	require.NotNil(t, cookie)
+
+	for _, b := range backends {
+		if b.GetURL().String() == first {
+			b.SetAlive(false)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	rr = httptest.NewRecorder()
+	lb.ServeHTTP(rr, req)
+
+	assert.NotEqual(t, first, rr.Body.String())
+	reissued := stickyCookie(t, rr)
+	require.NotNil(t, reissued)
+	assert.NotEqual(t, cookie.Value, reissued.Value)
+}
+
+// Test tampered cookies are ignored
+func TestSticky_TamperedCookie(t *testing.T) {
+	sp, err := serverpool.NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	// Each server answers with its own URL in the body
+	for i := 0; i < 2; i++ {
+		s := httptest.NewServer(nil)
+		s.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(s.URL))
+		})
+		defer s.Close()
+
+		u, err := url.Parse(s.URL)
+		require.NoError(t, err, "failed to parse url")
+		sp.AddBackend(backend.NewBackend(u))
+	}
+	lb := NewLoadBalancerWithOptions(sp, Options{StickySession: stickyOptions})
+	backends := sp.GetBackends()
+
+	// A valid cookie issued by the load balancer
+	rr := httptest.NewRecorder()
+	lb.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookie := stickyCookie(t, rr)
+	require.NotNil(t, cookie)
+
+	// Cookie signed with a different key
+	s := newStickySession(StickySessionOptions{Enabled: true, CookieName: "sticky", SigningKey: []byte("other")})
+	forged := s.cookie(backends[0])
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(forged)
+	_, ok := lb.(*loadBalancer).sticky.backendURL(req)
+	assert.False(t, ok)
+
+	parts := strings.Split(cookie.Value, ".")
+	require.Len(t, parts, 3)
+	parts[1] = "9999999999" // extend expiry without re-signing
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "sticky", Value: strings.Join(parts, ".")})
+	_, ok = lb.(*loadBalancer).sticky.backendURL(req)
+	assert.False(t, ok)
+}
+
+// Test expired cookies are ignored
+func TestSticky_ExpiredCookie(t *testing.T) {
+	s := newStickySession(StickySessionOptions{Enabled: true, CookieName: "sticky", SigningKey: []byte("k")})
+	s.ttl = -time.Minute // force an already expired cookie
+
+	u, err := url.Parse("http://127.0.0.1:8081")
+	require.NoError(t, err, "failed to parse url")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(s.cookie(backend.NewBackend(u)))
+
+	_, ok := s.backendURL(req)
+	assert.False(t, ok)
+}
+
+// Test sticky sessions are disabled by default
+func TestSticky_Disabled(t *testing.T) {
+	assert.Nil(t, newStickySession(StickySessionOptions{}))
+}
@@ -0,0 +1,32 @@
+package lb
+
+import (
+	"load-balancer/forwarded"
+	"load-balancer/requestid"
+	"load-balancer/tracing"
+	"net/http"
+)
+
+// startRequestSpan starts the server span of a request, continuing the trace of its traceparent header.
+func (lb *loadBalancer) startRequestSpan(r *http.Request) (*http.Request, *tracing.Span) {
+	r, span := lb.tracer.StartRequest(r, r.Method)
+	span.SetAttribute("http.request.method", r.Method)
+	span.SetAttribute("url.path", r.URL.Path)
+	if id := requestid.FromContext(r.Context()); id != "" {
+		span.SetAttribute("lb.request_id", id)
+	}
+	span.SetAttribute("client.address", forwarded.ClientIP(r))
+	return r, span
+}
+
+// endRequestSpan records the number of attempts on the server span and ends it.
+// state is nil if the request failed before the first attempt.
+func endRequestSpan(span *tracing.Span, state *retryState) {
+	if state != nil {
+		span.SetAttribute("lb.attempts", state.attempts)
+		if state.attempts == 0 {
+			span.SetError(errNoBackend)
+		}
+	}
+	span.End()
+}
@@ -0,0 +1,109 @@
+package lb
+
+import (
+	"context"
+	"fmt"
+	"load-balancer/backend"
+	"load-balancer/serverpool"
+	"load-balancer/tracing"
+	"load-balancer/tracing/tracingtest"
+	"load-balancer/utils"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test a request continues the caller trace, with a child span per attempt propagated to the backend
+func TestTracing_SpanPerAttempt(t *testing.T) {
+	c := tracingtest.NewCollector(t)
+	tracer := tracing.NewTracer(tracing.Options{Endpoint: c.Endpoint})
+
+	// Least connections picks idle backends in order, so the dead backend is tried first
+	sp, err := serverpool.NewServerPool(utils.LeastConnected)
+	require.NoError(t, err, "failed to create server pool")
+	l := NewLoadBalancerWithOptions(sp, Options{Tracer: tracer})
+
+	var received string
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(tracing.TraceparentHeader)
+	}))
+	defer healthy.Close()
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+
+	for _, raw := range []string{dead.URL, healthy.URL} {
+		u, err := url.Parse(raw)
+		require.NoError(t, err, "failed to parse url")
+		b := backend.NewBackendWithOptions(u, backend.Options{Tracer: tracer})
+		b.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, e error) {
+			if !AllowRetry(r) {
+				http.Error(w, fmt.Sprintf("service not available after %d attempts", GetAttempts(r)), http.StatusServiceUnavailable)
+				return
+			}
+			l.ServeHTTP(w, r)
+		})
+		sp.AddBackend(b)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set(tracing.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rr := httptest.NewRecorder()
+	l.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	require.NoError(t, tracer.Shutdown(context.Background()))
+
+	var attempts []tracingtest.Span
+	for _, s := range c.Spans() {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", s.TraceID)
+		if s.Name == "upstream GET" {
+			attempts = append(attempts, s)
+		}
+	}
+
+	server := c.Span("GET")
+	assert.Equal(t, "00f067aa0ba902b7", server.ParentSpanID)
+	assert.Equal(t, "2", server.Attr("lb.attempts").String())
+	assert.Equal(t, "/orders", server.Attr("url.path").String())
+
+	require.Len(t, attempts, 2)
+	for _, a := range attempts {
+		assert.Equal(t, server.SpanID, a.ParentSpanID)
+		switch a.Attr("lb.backend.url").String() {
+		case dead.URL:
+			assert.Equal(t, "0", a.Attr("lb.retry").String())
+			assert.Equal(t, 2, a.Status.Code)
+		case healthy.URL:
+			assert.Equal(t, "1", a.Attr("lb.retry").String())
+			assert.Equal(t, "200", a.Attr("http.response.status_code").String())
+			assert.Zero(t, a.Status.Code)
+			assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-"+a.SpanID+"-01", received)
+		default:
+			t.Errorf("unexpected backend %q", a.Attr("lb.backend.url").String())
+		}
+	}
+}
+
+// Test no traceparent is rewritten when tracing is disabled
+func TestTracing_Disabled(t *testing.T) {
+	var received string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(tracing.TraceparentHeader)
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err, "failed to parse url")
+
+	l := NewLoadBalancer(&recordingPool{peer: backend.NewBackend(u)})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(tracing.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	l.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", received)
+}
@@ -2,22 +2,33 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"regexp"
 	"syscall"
 	"time"
 
+	"load-balancer/accesslog"
+	"load-balancer/admin"
 	"load-balancer/backend"
+	"load-balancer/forwarded"
 	"load-balancer/lb"
+	"load-balancer/metrics"
+	"load-balancer/requestid"
 	"load-balancer/serverpool"
+	"load-balancer/tracing"
 	"load-balancer/utils"
 
 	"go.uber.org/zap"
 )
 
+// statusClientClosedRequest is logged for requests the client gave up on, after the nginx convention.
+const statusClientClosedRequest = 499
+
 func main() {
 	// Initialize the logger
 	logger := utils.InitLogger()
@@ -33,59 +44,245 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	// Create a server pool with the configured strategy
-	serverPool, err := serverpool.NewServerPool(utils.GetLBStrategy(config.Strategy))
+	// Create a server pool with the configured strategy, switchable on reload
+	serverPool, err := serverpool.NewDynamicPool(poolOptions(config, logger))
 	if err != nil {
 		logger.Fatal(err.Error())
 	}
-	loadBalancer := lb.NewLoadBalancer(serverPool)
+	// Export a span per request and per upstream attempt if enabled
+	var tracer *tracing.Tracer
+	if config.Tracing.Enabled {
+		tracer = tracing.NewTracer(tracing.Options{
+			Endpoint:    config.Tracing.Endpoint,
+			Headers:     config.Tracing.Headers,
+			ServiceName: config.Tracing.ServiceName,
+			SampleRatio: config.Tracing.SampleRatio,
+			Logger:      logger,
+		})
+	}
 
-	// Initialize backend servers
-	for _, u := range config.Backends {
-		endpoint, err := url.Parse(u)
+	// Resolve clients through trusted proxies and set the forwarding headers sent to backends
+	forwardedPolicy, err := newForwardedPolicy(config.ForwardedHeaders)
+	if err != nil {
+		logger.Fatal("invalid forwarded headers", zap.Error(err))
+	}
+
+	loadBalancer := lb.NewLoadBalancerWithOptions(serverPool, lb.Options{
+		MaxAttempts: config.MaxAttemptLimit,
+		Retry: lb.RetryOptions{
+			MaxBodySize:    config.Retry.MaxBodySize,
+			IdempotentOnly: config.Retry.IdempotentOnly,
+		},
+		StickySession: lb.StickySessionOptions{
+			Enabled:    config.StickySession.Enabled,
+			CookieName: config.StickySession.CookieName,
+			TTL:        time.Second * time.Duration(config.StickySession.TTL),
+			SigningKey: []byte(config.StickySession.SigningKey),
+		},
+		Tracer:    tracer,
+		Forwarded: forwardedPolicy,
+	})
+
+	// Record request, retry and health check metrics, served on the admin port
+	lbMetrics := metrics.New(serverPool)
+
+	// Eject backends that keep failing live traffic
+	outlierDetector := serverpool.NewOutlierDetector(serverPool, serverpool.OutlierOptions{
+		ConsecutiveFailures: config.OutlierDetection.ConsecutiveFailures,
+		BaseEjectionTime:    time.Second * time.Duration(config.OutlierDetection.BaseEjectionTime),
+		MaxEjectionTime:     time.Second * time.Duration(config.OutlierDetection.MaxEjectionTime),
+		MaxEjectionPercent:  config.OutlierDetection.MaxEjectionPercent,
+		Logger:              logger,
+	})
+	serverPool.SetRemoveObserver(func(b backend.Backend) {
+		outlierDetector.Forget(b)
+		lbMetrics.Forget(b)
+	})
+
+	// newBackend creates a backend from its configuration and the config current when it is added,
+	// and wires it to the load balancer
+	newBackend := func(config *utils.Config, bc utils.BackendConfig) (backend.Backend, error) {
+		endpoint, err := url.Parse(bc.URL)
 		if err != nil {
-			logger.Fatal(err.Error())
+			return nil, err
 		}
 
-		backendServer := backend.NewBackend(endpoint)
+		healthCheck, err := newHealthCheck(bc.HealthCheck)
+		if err != nil {
+			return nil, fmt.Errorf("invalid health check: %w", err)
+		}
+
+		opts := backend.Options{
+			Weight:           bc.Weight,
+			HealthCheck:      healthCheck,
+			HealthThresholds: healthThresholds(bc.HealthCheck),
+			SlowStart:        time.Second * time.Duration(config.SlowStart),
+			Tracer:           tracer,
+			RetryPolicy:      retryPolicy(config),
+			CircuitBreaker:   breakerOptions(config, logger),
+		}
+
+		backendServer := backend.NewBackendWithOptions(endpoint, opts)
+
+		// Feed upstream responses to the outlier detector
+		backendServer.SetResponseObserver(func(r *http.Request, statusCode int) {
+			outlierDetector.ObserveStatus(r.Context(), backendServer, statusCode)
+		})
+
+		backendServer.SetAttemptObserver(func(a backend.Attempt) {
+			lbMetrics.ObserveAttempt(backendServer, a)
+		})
 
 		// Configure the error handler for backend failures
 		backendServer.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, e error) {
-			logger.Error("error handling the request", zap.String("host", endpoint.Host), zap.Error(e))
-			backendServer.SetAlive(false)
+			// The client gave up, neither the backend is to blame nor is a retry of use
+			if backend.ClientGone(r) {
+				requestid.Logger(r.Context(), logger).Debug("client closed the request", zap.String("host", endpoint.Host), zap.Error(e))
+				w.WriteHeader(statusClientClosedRequest)
+				return
+			}
+
+			requestid.Logger(r.Context(), logger).Error("error handling the request", zap.String("host", endpoint.Host), zap.Error(e))
+
+			// Retryable statuses were already counted by the response observer,
+			// and requests rejected by the circuit breaker never reached the backend
+			var statusErr *backend.StatusError
+			if !errors.As(e, &statusErr) && !errors.Is(e, backend.ErrCircuitOpen) {
+				outlierDetector.ObserveFailure(r.Context(), backendServer)
+			}
 
 			if !lb.AllowRetry(r) {
-				http.Error(w, "service not available", http.StatusServiceUnavailable)
+				lbMetrics.ObserveFailure(backendServer, e, false)
+				http.Error(w, fmt.Sprintf("service not available after %d attempts", lb.GetAttempts(r)), http.StatusServiceUnavailable)
 				return
 			}
 
-			// Retry request with load balancer
-			loadBalancer.ServeHTTP(
-				w,
-				r.WithContext(context.WithValue(r.Context(), lb.RetryAttemptedKey, true)),
-			)
+			// Retry request on another backend
+			lbMetrics.ObserveFailure(backendServer, e, true)
+			loadBalancer.ServeHTTP(w, r)
 		})
 
+		return backendServer, nil
+	}
+
+	// Initialize backend servers
+	for _, bc := range config.Backends {
+		backendServer, err := newBackend(config, bc)
+		if err != nil {
+			logger.Fatal("invalid backend", zap.String("url", bc.URL), zap.Error(err))
+		}
 		serverPool.AddBackend(backendServer)
 	}
 
+	// Log every proxied request if enabled
+	var handler http.Handler = http.HandlerFunc(loadBalancer.ServeHTTP)
+	if config.AccessLog.Enabled {
+		output, err := accesslog.ParseOutput(config.AccessLog.Output, int64(config.AccessLog.MaxSize)<<20, config.AccessLog.MaxBackups)
+		if err != nil {
+			logger.Fatal("failed to open access log", zap.Error(err))
+		}
+		defer output.Close()
+
+		accessLog, err := accesslog.New(accesslog.Options{
+			Format:   config.AccessLog.Format,
+			Template: config.AccessLog.Template,
+			Output:   output,
+		})
+		if err != nil {
+			logger.Fatal("invalid access log", zap.Error(err))
+		}
+		handler = accessLog.Middleware(handler)
+	}
+
 	// Create HTTP server for the load balancer
 	server := http.Server{
 		Addr:    fmt.Sprintf(":%d", config.Port),
-		Handler: http.HandlerFunc(loadBalancer.ServeHTTP),
+		Handler: handler,
 	}
 
 	// Start periodic health checks in the background
-	go serverpool.LaunchHealthCheck(ctx, serverPool, logger)
+	healthMonitor := serverpool.NewHealthMonitor(serverPool, healthCheckOptions(config), logger)
+	healthMonitor.SetObserver(lbMetrics.ObserveHealthCheck)
+	go healthMonitor.Run(ctx)
+
+	// Reload the configuration on SIGHUP, and on file changes if polling is enabled
+	reload := &reloader{
+		path:       utils.ConfigPath,
+		pool:       serverPool,
+		monitor:    healthMonitor,
+		newBackend: newBackend,
+		logger:     logger,
+	}
+	reload.config.Store(config)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-hup:
+				reload.reload("SIGHUP")
+			case <-ctx.Done():
+				signal.Stop(hup)
+				return
+			}
+		}
+	}()
+	if config.ReloadInterval > 0 {
+		go utils.WatchFile(ctx, utils.ConfigPath, time.Second*time.Duration(config.ReloadInterval), func() {
+			reload.reload("file change")
+		})
+	}
+
+	// Start the admin API on its own port
+	var adminServer *http.Server
+	if config.Admin.Enabled {
+		adminHandler, err := admin.NewHandler(serverPool, admin.Options{
+			Token:        config.Admin.Token,
+			DrainTimeout: time.Second * time.Duration(config.DrainTimeout),
+			NewBackend: func(u *url.URL, weight int) (backend.Backend, error) {
+				current := reload.config.Load()
+				return newBackend(current, current.BackendDefaults(utils.BackendConfig{URL: u.String(), Weight: weight}))
+			},
+			HealthCheck: healthMonitor.CheckAll,
+			Metrics:     lbMetrics,
+			Logger:      logger,
+		})
+		if err != nil {
+			logger.Fatal("failed to create admin API", zap.Error(err))
+		}
+
+		adminServer = &http.Server{
+			Addr:    fmt.Sprintf(":%d", config.Admin.Port),
+			Handler: adminHandler,
+		}
+		go func() {
+			logger.Info("admin API started", zap.Int("port", config.Admin.Port))
+			if err := adminServer.ListenAndServe(); err != http.ErrServerClosed {
+				logger.Fatal("admin ListenAndServe() error", zap.Error(err))
+			}
+		}()
+	}
 
 	// Handle graceful shutdown
+	shutdown := make(chan struct{})
 	go func() {
+		defer close(shutdown)
 		<-ctx.Done() // Wait for terminatino signal(SIGINT/SIGTERM)
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(config.ShutdownTimeout))
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(reload.config.Load().ShutdownTimeout))
 		defer cancel()
 
+		if adminServer != nil {
+			if err := adminServer.Shutdown(shutdownCtx); err != nil {
+				logger.Error("failed to shutdown admin API", zap.Error(err))
+			}
+		}
+		// Not fatal, the spans of the requests served meanwhile are still exported
 		if err := server.Shutdown(shutdownCtx); err != nil {
-			logger.Fatal("failed to shutdown", zap.Error(err))
+			logger.Error("failed to shutdown", zap.Error(err))
+		}
+		if err := tracer.Shutdown(shutdownCtx); err != nil {
+			logger.Error("failed to export remaining spans", zap.Error(err))
 		}
 	}()
 
@@ -94,4 +291,62 @@ func main() {
 	if err := server.ListenAndServe(); err != http.ErrServerClosed {
 		logger.Fatal("ListenAndServe() error", zap.Error(err))
 	}
+	// ListenAndServe returns as soon as the shutdown starts, wait for in-flight requests and spans
+	<-shutdown
+}
+
+// newHealthCheck converts the health check configuration of a backend.
+func newHealthCheck(c utils.HealthCheckConfig) (backend.HealthChecker, error) {
+	switch c.Type {
+	case "", "http":
+	case "tcp":
+		return backend.TCPHealthCheck{}, nil
+	case "grpc":
+		return backend.GRPCHealthCheck{Service: c.Service}, nil
+	default:
+		return nil, fmt.Errorf("unknown health check type %q", c.Type)
+	}
+
+	statuses, err := backend.ParseStatusRanges(c.ExpectedStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	var bodyRegex *regexp.Regexp
+	if c.BodyRegex != "" {
+		bodyRegex, err = regexp.Compile(c.BodyRegex)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	headers := make(http.Header, len(c.Headers))
+	for k, v := range c.Headers {
+		headers.Set(k, v)
+	}
+
+	return backend.HealthCheck{
+		Path:         c.Path,
+		Method:       c.Method,
+		Headers:      headers,
+		Host:         c.Host,
+		Statuses:     statuses,
+		BodyContains: c.BodyContains,
+		BodyRegex:    bodyRegex,
+	}, nil
+}
+
+// newForwardedPolicy converts the forwarded headers configuration.
+func newForwardedPolicy(c utils.ForwardedConfig) (forwarded.Policy, error) {
+	mode, err := forwarded.ParseMode(c.Mode)
+	if err != nil {
+		return forwarded.Policy{}, err
+	}
+
+	trusted, err := forwarded.ParseTrustedProxies(c.TrustedProxies)
+	if err != nil {
+		return forwarded.Policy{}, err
+	}
+
+	return forwarded.Policy{Mode: mode, Forwarded: c.Forwarded, TrustedProxies: trusted}, nil
 }
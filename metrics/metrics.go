@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"errors"
+	"load-balancer/backend"
+	"load-balancer/serverpool"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Metrics records the load balancer metrics and serves them for Prometheus to scrape.
+type Metrics struct {
+	registry *Registry
+	pool     serverpool.ServerPool // read on every scrape
+
+	requests          *CounterVec
+	requestDuration   *HistogramVec
+	failovers         *CounterVec
+	retriesExhausted  *CounterVec
+	healthCheckTiming *HistogramVec
+
+	mux sync.Mutex
+	up  map[backend.Backend]bool // alive status from the last health check
+}
+
+// New creates the load balancer metrics for the backends of the pool.
+func New(pool serverpool.ServerPool) *Metrics {
+	r := NewRegistry()
+	m := &Metrics{
+		registry: r,
+		pool:     pool,
+		up:       make(map[backend.Backend]bool),
+	}
+
+	m.requests = r.NewCounterVec("lb_requests_total",
+		"Request attempts forwarded to a backend, by backend, method and upstream status class (error when the backend did not respond).",
+		"backend", "method", "code")
+	m.requestDuration = r.NewHistogramVec("lb_request_duration_seconds",
+		"Time until a backend answered with response headers or failed.",
+		nil, "backend")
+	m.failovers = r.NewCounterVec("lb_failovers_total",
+		"Failed attempts retried on another backend, by failed backend and reason.",
+		"backend", "reason")
+	m.retriesExhausted = r.NewCounterVec("lb_retries_exhausted_total",
+		"Requests that failed on their last allowed attempt, by backend and reason.",
+		"backend", "reason")
+	r.NewGaugeFunc("lb_backend_active_connections",
+		"Requests in flight to a backend.",
+		[]string{"backend"}, m.collectConnections)
+	r.NewGaugeFunc("lb_backend_up",
+		"Whether the last health check left the backend alive (1) or dead (0).",
+		[]string{"backend"}, m.collectUp)
+	m.healthCheckTiming = r.NewHistogramVec("lb_health_check_duration_seconds",
+		"Time taken by a health check, by backend and result.",
+		nil, "backend", "result")
+
+	return m
+}
+
+// ServeHTTP serves a scrape of the metrics.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.registry.ServeHTTP(w, r)
+}
+
+// ObserveAttempt records a request attempt at the backend.
+func (m *Metrics) ObserveAttempt(b backend.Backend, a backend.Attempt) {
+	u := b.GetURL().String()
+	m.requests.Inc(u, method(a.Request.Method), statusClass(a.StatusCode))
+	m.requestDuration.Observe(a.Duration.Seconds(), u)
+}
+
+// ObserveFailure records a failed attempt at the backend, retried on another backend or not.
+func (m *Metrics) ObserveFailure(b backend.Backend, err error, retried bool) {
+	if retried {
+		m.failovers.Inc(b.GetURL().String(), failureReason(err))
+		return
+	}
+	m.retriesExhausted.Inc(b.GetURL().String(), failureReason(err))
+}
+
+// ObserveHealthCheck records a health check result.
+func (m *Metrics) ObserveHealthCheck(res serverpool.HealthCheckResult) {
+	result := "success"
+	if res.Err != nil {
+		result = "failure"
+	}
+	m.healthCheckTiming.Observe(res.Duration.Seconds(), res.Backend.GetURL().String(), result)
+
+	m.mux.Lock()
+	m.up[res.Backend] = res.Alive
+	m.mux.Unlock()
+}
+
+// Forget deletes the series of a backend removed from the pool.
+func (m *Metrics) Forget(b backend.Backend) {
+	u := b.GetURL().String()
+	m.requests.DeletePartialMatch(map[string]string{"backend": u})
+	m.requestDuration.Delete(u)
+	m.failovers.DeletePartialMatch(map[string]string{"backend": u})
+	m.retriesExhausted.DeletePartialMatch(map[string]string{"backend": u})
+	m.healthCheckTiming.DeletePartialMatch(map[string]string{"backend": u})
+
+	m.mux.Lock()
+	delete(m.up, b)
+	m.mux.Unlock()
+}
+
+// collectConnections emits the active connections of every backend in the pool.
+func (m *Metrics) collectConnections(emit func(float64, ...string)) {
+	for _, b := range m.pool.GetBackends() {
+		emit(float64(b.GetActiveConnections()), b.GetURL().String())
+	}
+}
+
+// collectUp emits the health check status of every backend in the pool.
+// Backends not checked yet report their current alive status, and removed backends are forgotten.
+func (m *Metrics) collectUp(emit func(float64, ...string)) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	current := make(map[backend.Backend]bool)
+	for _, b := range m.pool.GetBackends() {
+		alive, ok := m.up[b]
+		if !ok {
+			alive = b.IsAlive()
+		}
+		current[b] = alive
+
+		v := 0.0
+		if alive {
+			v = 1
+		}
+		emit(v, b.GetURL().String())
+	}
+
+	for b := range m.up {
+		if _, ok := current[b]; !ok {
+			delete(m.up, b)
+		}
+	}
+}
+
+// statusClass returns the class of the status code, "2xx" for 204, and "error" without a response.
+func statusClass(code int) string {
+	if code < 100 || code > 599 {
+		return "error"
+	}
+	return strconv.Itoa(code/100) + "xx"
+}
+
+// method returns the request method, with non-standard methods grouped to bound the number of series.
+func method(m string) string {
+	switch m {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return m
+	default:
+		return "OTHER"
+	}
+}
+
+// failureReason classifies the error of a failed attempt.
+func failureReason(err error) string {
+	var statusErr *backend.StatusError
+	switch {
+	case errors.As(err, &statusErr):
+		return "status"
+	case errors.Is(err, backend.ErrCircuitOpen):
+		return "circuit_open"
+	default:
+		return "transport"
+	}
+}
@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"load-balancer/backend"
+	"load-balancer/serverpool"
+	"load-balancer/utils"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// Test a scrape reports requests, latencies, connections, health and failovers of the pool backends
+func TestMetrics_Scrape(t *testing.T) {
+	sp, err := serverpool.NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+	m := New(sp)
+
+	okServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer okServer.Close()
+	failServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failServer.Close()
+
+	for _, raw := range []string{okServer.URL, failServer.URL} {
+		u, err := url.Parse(raw)
+		require.NoError(t, err, "failed to parse url")
+		b := backend.NewBackend(u)
+		b.SetAttemptObserver(func(a backend.Attempt) { m.ObserveAttempt(b, a) })
+		sp.AddBackend(b)
+	}
+
+	for _, b := range sp.GetBackends() {
+		b.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	sp.GetBackends()[0].ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PURGE", "/", nil))
+
+	monitor := serverpool.NewHealthMonitor(sp, serverpool.HealthCheckOptions{}, zap.NewNop())
+	monitor.SetObserver(m.ObserveHealthCheck)
+	monitor.CheckAll(context.Background())
+
+	m.ObserveFailure(sp.GetBackends()[1], &backend.StatusError{StatusCode: http.StatusServiceUnavailable}, true)
+	m.ObserveFailure(sp.GetBackends()[1], errors.New("connection refused"), false)
+
+	s := httptest.NewServer(m)
+	defer s.Close()
+
+	resp, err := http.Get(s.URL)
+	require.NoError(t, err, "failed to scrape")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	buf, err := io.ReadAll(resp.Body)
+	require.NoError(t, err, "failed to read scrape")
+	body := string(buf)
+
+	ok, fail := okServer.URL, failServer.URL
+	for _, line := range []string{
+		`lb_requests_total{backend="` + ok + `",method="GET",code="2xx"} 1`,
+		`lb_requests_total{backend="` + ok + `",method="OTHER",code="2xx"} 1`,
+		`lb_requests_total{backend="` + fail + `",method="GET",code="5xx"} 1`,
+		`lb_request_duration_seconds_count{backend="` + ok + `"} 2`,
+		`lb_request_duration_seconds_bucket{backend="` + fail + `",le="+Inf"} 1`,
+		`lb_failovers_total{backend="` + fail + `",reason="status"} 1`,
+		`lb_retries_exhausted_total{backend="` + fail + `",reason="transport"} 1`,
+		`lb_backend_active_connections{backend="` + ok + `"} 0`,
+		`lb_backend_up{backend="` + ok + `"} 1`,
+		`lb_backend_up{backend="` + fail + `"} 0`,
+		`lb_health_check_duration_seconds_count{backend="` + ok + `",result="success"} 1`,
+		`lb_health_check_duration_seconds_count{backend="` + fail + `",result="failure"} 1`,
+		`# TYPE lb_request_duration_seconds histogram`,
+	} {
+		assert.Contains(t, body, line+"\n")
+	}
+}
+
+// Test removed backends disappear from the gauges
+func TestMetrics_RemovedBackend(t *testing.T) {
+	sp, err := serverpool.NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+	m := New(sp)
+
+	u, err := url.Parse("http://127.0.0.1:8081")
+	require.NoError(t, err, "failed to parse url")
+	b := backend.NewBackend(u)
+	sp.AddBackend(b)
+	m.ObserveHealthCheck(serverpool.HealthCheckResult{Backend: b, Alive: true})
+
+	assert.Contains(t, scrape(t, m), `lb_backend_up{backend="`+b.GetURL().String()+`"} 1`)
+
+	sp.RemoveBackend(b.GetURL().String())
+	body := scrape(t, m)
+	assert.NotContains(t, body, "lb_backend_up{")
+	assert.NotContains(t, body, "lb_backend_active_connections{")
+	assert.Empty(t, m.up)
+}
+
+// Test the request, failover and health check series of a forgotten backend are deleted
+func TestMetrics_Forget(t *testing.T) {
+	sp, err := serverpool.NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+	m := New(sp)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err, "failed to parse url")
+	b := backend.NewBackend(u)
+	b.SetAttemptObserver(func(a backend.Attempt) { m.ObserveAttempt(b, a) })
+	sp.AddBackend(b)
+
+	b.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	m.ObserveFailure(b, errors.New("connection refused"), true)
+	m.ObserveFailure(b, errors.New("connection refused"), false)
+	m.ObserveHealthCheck(serverpool.HealthCheckResult{Backend: b, Alive: true})
+
+	label := `backend="` + b.GetURL().String() + `"`
+	require.Contains(t, scrape(t, m), label)
+
+	sp.RemoveBackend(b.GetURL().String())
+	m.Forget(b)
+	assert.NotContains(t, scrape(t, m), label)
+	assert.Empty(t, m.up)
+}
+
+// Test status classes and failure reasons
+func TestMetrics_Labels(t *testing.T) {
+	assert.Equal(t, "2xx", statusClass(204))
+	assert.Equal(t, "5xx", statusClass(503))
+	assert.Equal(t, "error", statusClass(0))
+
+	assert.Equal(t, "status", failureReason(&backend.StatusError{StatusCode: 502}))
+	assert.Equal(t, "circuit_open", failureReason(backend.ErrCircuitOpen))
+	assert.Equal(t, "transport", failureReason(errors.New("connection refused")))
+}
@@ -0,0 +1,431 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// contentType is the Prometheus text exposition format served by the registry.
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// collector writes a metric family in the text exposition format.
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// Registry holds metric families and serves them in the Prometheus text exposition format.
+type Registry struct {
+	mux        sync.Mutex
+	collectors []collector
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(c collector) {
+	r.mux.Lock()
+	r.collectors = append(r.collectors, c)
+	r.mux.Unlock()
+}
+
+// WriteTo writes every metric family to w in registration order.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mux.Lock()
+	collectors := slices.Clone(r.collectors)
+	r.mux.Unlock()
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// ServeHTTP serves a scrape of the registry.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	_, _ = r.WriteTo(w)
+}
+
+// family is the name, help and label names shared by every series of a metric.
+type family struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (f family) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.kind)
+}
+
+// writeSample writes a single sample, extra is an additional label such as the histogram bucket.
+func (f family) writeSample(w *bufio.Writer, suffix string, labelValues []string, extra [2]string, v float64) {
+	w.WriteString(f.name)
+	w.WriteString(suffix)
+
+	if len(f.labels) > 0 || extra[0] != "" {
+		w.WriteByte('{')
+		for i, name := range f.labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, name, escapeLabel(labelValues[i]))
+		}
+		if extra[0] != "" {
+			if len(f.labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, extra[0], escapeLabel(extra[1]))
+		}
+		w.WriteByte('}')
+	}
+
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+// key identifies a series by its label values.
+func (f family) key(labelValues []string) string {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metric %s: %d label values for %d labels", f.name, len(labelValues), len(f.labels)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+// matches reports whether the label values have the given value for every named label.
+func (f family) matches(labelValues []string, labels map[string]string) bool {
+	for name, value := range labels {
+		i := slices.Index(f.labels, name)
+		if i < 0 || labelValues[i] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// deleteSeries deletes the series of the exact label values, returns false if there was none.
+func deleteSeries[V any](f family, series map[string]V, labelValues []string) bool {
+	k := f.key(labelValues)
+	if _, ok := series[k]; !ok {
+		return false
+	}
+	delete(series, k)
+	return true
+}
+
+// deleteMatching deletes every series matching the labels, returns how many were deleted.
+func deleteMatching[V any](f family, series map[string]V, labels map[string]string, labelValues func(V) []string) int {
+	n := 0
+	for k, s := range series {
+		if f.matches(labelValues(s), labels) {
+			delete(series, k)
+			n++
+		}
+	}
+	return n
+}
+
+// series is a labeled value of a counter or gauge.
+type series struct {
+	labelValues []string
+	value       float64
+}
+
+// valueVec stores the series of a counter or gauge.
+type valueVec struct {
+	family
+	mux    sync.Mutex
+	series map[string]*series
+}
+
+func (v *valueVec) add(delta float64, labelValues []string) {
+	k := v.key(labelValues)
+
+	v.mux.Lock()
+	defer v.mux.Unlock()
+
+	s, ok := v.series[k]
+	if !ok {
+		s = &series{labelValues: slices.Clone(labelValues)}
+		v.series[k] = s
+	}
+	s.value += delta
+}
+
+func (v *valueVec) set(value float64, labelValues []string) {
+	k := v.key(labelValues)
+
+	v.mux.Lock()
+	defer v.mux.Unlock()
+
+	s, ok := v.series[k]
+	if !ok {
+		s = &series{labelValues: slices.Clone(labelValues)}
+		v.series[k] = s
+	}
+	s.value = value
+}
+
+func (v *valueVec) delete(labelValues []string) bool {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	return deleteSeries(v.family, v.series, labelValues)
+}
+
+func (v *valueVec) deleteMatching(labels map[string]string) int {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	return deleteMatching(v.family, v.series, labels, func(s *series) []string { return s.labelValues })
+}
+
+func (v *valueVec) write(w *bufio.Writer) {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+
+	v.writeHeader(w)
+	for _, k := range sortedKeys(v.series) {
+		s := v.series[k]
+		v.writeSample(w, "", s.labelValues, [2]string{}, s.value)
+	}
+}
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec struct {
+	vec *valueVec
+}
+
+// NewCounterVec registers a counter with the given label names.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &valueVec{family: family{name: name, help: help, kind: "counter", labels: labels}, series: make(map[string]*series)}
+	r.register(v)
+	return &CounterVec{vec: v}
+}
+
+// Inc increments the counter of the given label values.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.vec.add(1, labelValues)
+}
+
+// Add adds a non-negative delta to the counter of the given label values.
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.vec.add(delta, labelValues)
+}
+
+// Delete removes the counter of the given label values, so a gone backend stops being exported.
+// Returns false if there was none.
+func (c *CounterVec) Delete(labelValues ...string) bool {
+	return c.vec.delete(labelValues)
+}
+
+// DeletePartialMatch removes every counter whose labels have the given values, and returns how many were removed.
+func (c *CounterVec) DeletePartialMatch(labels map[string]string) int {
+	return c.vec.deleteMatching(labels)
+}
+
+// GaugeVec is a gauge partitioned by labels.
+type GaugeVec struct {
+	vec *valueVec
+}
+
+// NewGaugeVec registers a gauge with the given label names.
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	v := &valueVec{family: family{name: name, help: help, kind: "gauge", labels: labels}, series: make(map[string]*series)}
+	r.register(v)
+	return &GaugeVec{vec: v}
+}
+
+// Set sets the gauge of the given label values.
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.vec.set(value, labelValues)
+}
+
+// Add adds a delta, possibly negative, to the gauge of the given label values.
+func (g *GaugeVec) Add(delta float64, labelValues ...string) {
+	g.vec.add(delta, labelValues)
+}
+
+// Delete removes the gauge of the given label values. Returns false if there was none.
+func (g *GaugeVec) Delete(labelValues ...string) bool {
+	return g.vec.delete(labelValues)
+}
+
+// DeletePartialMatch removes every gauge whose labels have the given values, and returns how many were removed.
+func (g *GaugeVec) DeletePartialMatch(labels map[string]string) int {
+	return g.vec.deleteMatching(labels)
+}
+
+// gaugeFunc is a gauge whose series are produced on every scrape.
+type gaugeFunc struct {
+	family
+	collect func(emit func(value float64, labelValues ...string))
+}
+
+// NewGaugeFunc registers a gauge whose series are produced by collect on every scrape.
+// Use it for values owned elsewhere, such as the active connections of backends.
+func (r *Registry) NewGaugeFunc(name, help string, labels []string, collect func(emit func(value float64, labelValues ...string))) {
+	r.register(&gaugeFunc{family: family{name: name, help: help, kind: "gauge", labels: labels}, collect: collect})
+}
+
+func (g *gaugeFunc) write(w *bufio.Writer) {
+	g.writeHeader(w)
+	g.collect(func(value float64, labelValues ...string) {
+		g.key(labelValues) // validate the label count
+		g.writeSample(w, "", labelValues, [2]string{}, value)
+	})
+}
+
+// DefaultBuckets are the upper bounds, in seconds, of latency histograms.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// histogram is a labeled distribution of observations.
+type histogram struct {
+	labelValues []string
+	counts      []uint64 // per bucket, not cumulative
+	count       uint64
+	sum         float64
+}
+
+// HistogramVec is a histogram partitioned by labels.
+type HistogramVec struct {
+	family
+	buckets []float64
+	mux     sync.Mutex
+	series  map[string]*histogram
+}
+
+// NewHistogramVec registers a histogram with the given bucket upper bounds and label names.
+// Buckets must be sorted in increasing order, nil uses DefaultBuckets.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	if !slices.IsSorted(buckets) {
+		panic(fmt.Sprintf("metric %s: buckets not sorted", name))
+	}
+
+	h := &HistogramVec{
+		family:  family{name: name, help: help, kind: "histogram", labels: labels},
+		buckets: buckets,
+		series:  make(map[string]*histogram),
+	}
+	r.register(h)
+	return h
+}
+
+// Observe adds an observation to the histogram of the given label values.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	k := h.key(labelValues)
+
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	s, ok := h.series[k]
+	if !ok {
+		s = &histogram{labelValues: slices.Clone(labelValues), counts: make([]uint64, len(h.buckets))}
+		h.series[k] = s
+	}
+
+	if i, _ := slices.BinarySearch(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+// Delete removes the histogram of the given label values. Returns false if there was none.
+func (h *HistogramVec) Delete(labelValues ...string) bool {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	return deleteSeries(h.family, h.series, labelValues)
+}
+
+// DeletePartialMatch removes every histogram whose labels have the given values, and returns how many were removed.
+func (h *HistogramVec) DeletePartialMatch(labels map[string]string) int {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	return deleteMatching(h.family, h.series, labels, func(s *histogram) []string { return s.labelValues })
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	h.writeHeader(w)
+	for _, k := range sortedKeys(h.series) {
+		s := h.series[k]
+
+		var cumulative uint64
+		for i, le := range h.buckets {
+			cumulative += s.counts[i]
+			h.writeSample(w, "_bucket", s.labelValues, [2]string{"le", formatFloat(le)}, float64(cumulative))
+		}
+		h.writeSample(w, "_bucket", s.labelValues, [2]string{"le", "+Inf"}, float64(s.count))
+		h.writeSample(w, "_sum", s.labelValues, [2]string{}, s.sum)
+		h.writeSample(w, "_count", s.labelValues, [2]string{}, float64(s.count))
+	}
+}
+
+// sortedKeys returns the keys of the map in order, so scrapes are stable.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
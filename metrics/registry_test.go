@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scrape serves a scrape of the handler and returns the body.
+func scrape(t *testing.T, h http.Handler) string {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, contentType, rr.Header().Get("Content-Type"))
+	return rr.Body.String()
+}
+
+// Test counters and gauges are written in the text exposition format, sorted by labels
+func TestRegistry_CounterGauge(t *testing.T) {
+	r := NewRegistry()
+
+	c := r.NewCounterVec("requests_total", "Requests served.", "code")
+	c.Inc("5xx")
+	c.Inc("2xx")
+	c.Add(2, "2xx")
+
+	g := r.NewGaugeVec("temperature", "Current temperature.")
+	g.Set(21.5)
+	g.Add(-1)
+
+	assert.Equal(t, `# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{code="2xx"} 3
+requests_total{code="5xx"} 1
+# HELP temperature Current temperature.
+# TYPE temperature gauge
+temperature 20.5
+`, scrape(t, r))
+}
+
+// Test histogram buckets are cumulative and end with +Inf, sum and count
+func TestRegistry_Histogram(t *testing.T) {
+	r := NewRegistry()
+
+	h := r.NewHistogramVec("duration_seconds", "Duration.", []float64{0.5, 1}, "backend")
+	h.Observe(0.25, "a")
+	h.Observe(0.5, "a")
+	h.Observe(0.75, "a")
+	h.Observe(3, "a")
+
+	assert.Equal(t, `# HELP duration_seconds Duration.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{backend="a",le="0.5"} 2
+duration_seconds_bucket{backend="a",le="1"} 3
+duration_seconds_bucket{backend="a",le="+Inf"} 4
+duration_seconds_sum{backend="a"} 4.5
+duration_seconds_count{backend="a"} 4
+`, scrape(t, r))
+}
+
+// Test series are deleted by their exact label values or by a partial match
+func TestRegistry_Delete(t *testing.T) {
+	r := NewRegistry()
+
+	c := r.NewCounterVec("requests_total", "Requests served.", "backend", "code")
+	c.Inc("a", "2xx")
+	c.Inc("a", "5xx")
+	c.Inc("b", "2xx")
+	g := r.NewGaugeVec("queue", "Queued requests.", "backend")
+	g.Set(3, "a")
+	h := r.NewHistogramVec("duration_seconds", "Duration.", []float64{1}, "backend")
+	h.Observe(0.5, "a")
+
+	assert.True(t, c.Delete("a", "5xx"))
+	assert.False(t, c.Delete("a", "5xx"))
+	assert.Equal(t, 1, c.DeletePartialMatch(map[string]string{"backend": "a"}))
+	assert.Equal(t, 0, c.DeletePartialMatch(map[string]string{"unknown": "a"}))
+	assert.True(t, g.Delete("a"))
+	assert.Equal(t, 1, h.DeletePartialMatch(map[string]string{"backend": "a"}))
+
+	assert.Equal(t, `# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{backend="b",code="2xx"} 1
+# HELP queue Queued requests.
+# TYPE queue gauge
+# HELP duration_seconds Duration.
+# TYPE duration_seconds histogram
+`, scrape(t, r))
+}
+
+// Test gauge funcs are collected on every scrape
+func TestRegistry_GaugeFunc(t *testing.T) {
+	r := NewRegistry()
+
+	values := map[string]float64{"a": 1}
+	r.NewGaugeFunc("connections", "Connections.", []string{"backend"}, func(emit func(float64, ...string)) {
+		for _, k := range sortedKeys(values) {
+			emit(values[k], k)
+		}
+	})
+	assert.Contains(t, scrape(t, r), `connections{backend="a"} 1`)
+
+	values["b"] = 2
+	delete(values, "a")
+	body := scrape(t, r)
+	assert.NotContains(t, body, `backend="a"`)
+	assert.Contains(t, body, `connections{backend="b"} 2`)
+}
+
+// Test help text and label values are escaped
+func TestRegistry_Escaping(t *testing.T) {
+	r := NewRegistry()
+
+	r.NewCounterVec("escaped_total", "Line one\nline \\ two.", "path").Inc("a\"b\\c\nd")
+
+	body := scrape(t, r)
+	assert.Contains(t, body, `# HELP escaped_total Line one\nline \\ two.`)
+	assert.Contains(t, body, `escaped_total{path="a\"b\\c\nd"} 1`)
+	assert.Equal(t, 3, strings.Count(body, "\n"))
+}
+
+// Test a wrong number of label values is a programming error
+func TestRegistry_LabelCount(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("requests_total", "Requests.", "code", "method")
+
+	assert.Panics(t, func() { c.Inc("2xx") })
+	assert.Panics(t, func() { c.Add(-1, "2xx", "GET") })
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"load-balancer/backend"
+	"load-balancer/lb"
+	"load-balancer/serverpool"
+	"load-balancer/utils"
+
+	"go.uber.org/zap"
+)
+
+// reconfigurable is implemented by backends whose health check, slow start, retry policy and
+// circuit breaker can change at runtime.
+type reconfigurable interface {
+	SetHealthChecker(backend.HealthChecker)
+	SetHealthThresholds(backend.HealthThresholds)
+	SetSlowStart(time.Duration)
+	SetRetryPolicy(backend.RetryPolicy)
+	SetCircuitBreaker(backend.BreakerOptions)
+	DisableCircuitBreaker()
+}
+
+// reloader applies changes of the configuration file to the running load balancer.
+type reloader struct {
+	path       string
+	mux        sync.Mutex // serializes reloads
+	config     atomic.Pointer[utils.Config]
+	pool       *serverpool.DynamicPool
+	monitor    *serverpool.HealthMonitor
+	newBackend func(*utils.Config, utils.BackendConfig) (backend.Backend, error)
+	logger     *zap.Logger
+}
+
+// reload reads the configuration file and applies the differences with the running configuration:
+// backends are added, updated or drained, the strategy is switched, timeouts are changed and the
+// retry policy and circuit breaker of every backend are replaced.
+// Settings read once at startup are only reported, see restartSettings.
+// An invalid configuration is rejected as a whole, the running configuration is kept.
+// Only backends removed from the file are drained, backends added through the admin API are left alone.
+// A backend added back to the file while it is still draining keeps its place in the pool.
+func (r *reloader) reload(trigger string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	next, err := utils.LoadLBConfig(r.path)
+	if err == nil {
+		err = validateConfig(next)
+	}
+	if err != nil {
+		r.logger.Error("config reload rejected, keeping the running config", zap.String("trigger", trigger), zap.Error(err))
+		return
+	}
+
+	prev := r.config.Load()
+	r.config.Store(next)
+
+	if changed := restartSettings(prev, next); len(changed) > 0 {
+		r.logger.Warn("config changes need a restart to apply", zap.Strings("settings", changed))
+	}
+
+	// Switch the strategy atomically
+	if opts := poolOptions(next, r.logger); opts != r.pool.GetOptions() {
+		if err := r.pool.SetOptions(opts); err != nil {
+			r.logger.Error("failed to switch strategy", zap.Error(err))
+		} else {
+			r.logger.Info("strategy switched", zap.String("strategy", next.Strategy))
+		}
+	}
+
+	r.monitor.SetOptions(healthCheckOptions(next))
+
+	running := make(map[string]backend.Backend)
+	for _, b := range r.pool.GetBackends() {
+		running[b.GetURL().String()] = b
+	}
+
+	wanted := make(map[string]struct{})
+	for _, bc := range next.Backends {
+		u, _ := url.Parse(bc.URL) // validated above
+		wanted[u.String()] = struct{}{}
+
+		b, ok := running[u.String()]
+		if !ok {
+			added, err := r.newBackend(next, bc)
+			if err != nil {
+				r.logger.Error("failed to add backend", zap.String("url", bc.URL), zap.Error(err))
+				continue
+			}
+			r.pool.AddBackend(added)
+			r.logger.Info("backend added", zap.String("url", bc.URL))
+			continue
+		}
+
+		// Added back before its drain finished
+		if b.IsDraining() {
+			if !r.pool.CancelDrain(b) {
+				b.SetDraining(false)
+			}
+			r.logger.Info("backend drain cancelled", zap.String("url", bc.URL))
+		}
+
+		// Update the backend in place, keeping its connections and state
+		b.SetWeight(bc.Weight)
+		if rb, ok := b.(reconfigurable); ok {
+			hc, _ := newHealthCheck(bc.HealthCheck) // validated above
+			rb.SetHealthChecker(hc)
+			rb.SetHealthThresholds(healthThresholds(bc.HealthCheck))
+		}
+	}
+
+	// Pool wide settings also apply to the backends added through the admin API
+	for _, b := range r.pool.GetBackends() {
+		rb, ok := b.(reconfigurable)
+		if !ok {
+			continue
+		}
+		rb.SetSlowStart(time.Second * time.Duration(next.SlowStart))
+		rb.SetRetryPolicy(retryPolicy(next))
+
+		// A new breaker starts closed, keep the running one while its settings are unchanged
+		if next.CircuitBreaker != prev.CircuitBreaker {
+			if opts := breakerOptions(next, r.logger); opts != nil {
+				rb.SetCircuitBreaker(*opts)
+			} else {
+				rb.DisableCircuitBreaker()
+			}
+		}
+	}
+
+	// Backends removed from the file finish their in-flight requests first
+	drainTimeout := time.Second * time.Duration(next.DrainTimeout)
+	for _, bc := range prev.Backends {
+		u, _ := url.Parse(bc.URL) // validated when loaded
+		b, ok := running[u.String()]
+		if _, keep := wanted[u.String()]; keep || !ok || b.IsDraining() {
+			continue
+		}
+		r.pool.DrainBackend(u.String(), drainTimeout)
+	}
+
+	r.logger.Info("config reloaded", zap.String("trigger", trigger), zap.Int("backends", len(next.Backends)))
+}
+
+// restartSettings returns the settings changed between two configurations that are only read at startup.
+func restartSettings(prev, next *utils.Config) []string {
+	var changed []string
+	for _, s := range []struct {
+		name    string
+		changed bool
+	}{
+		{"lb_port", next.Port != prev.Port},
+		{"admin", next.Admin != prev.Admin},
+		{"max_attempt_limit", next.MaxAttemptLimit != prev.MaxAttemptLimit},
+		{"reload_interval", next.ReloadInterval != prev.ReloadInterval},
+		{"sticky_session", !reflect.DeepEqual(next.StickySession, prev.StickySession)},
+		{"retry.max_body_size", next.Retry.MaxBodySize != prev.Retry.MaxBodySize},
+		{"retry.idempotent_only", next.Retry.IdempotentOnly != prev.Retry.IdempotentOnly},
+		{"outlier_detection", next.OutlierDetection != prev.OutlierDetection},
+		{"access_log", next.AccessLog != prev.AccessLog},
+		{"tracing", !reflect.DeepEqual(next.Tracing, prev.Tracing)},
+		{"forwarded_headers", !reflect.DeepEqual(next.ForwardedHeaders, prev.ForwardedHeaders)},
+	} {
+		if s.changed {
+			changed = append(changed, s.name)
+		}
+	}
+	return changed
+}
+
+// validateConfig checks the parts of the configuration that are only parsed when applied.
+func validateConfig(c *utils.Config) error {
+	if _, err := serverpool.NewServerPoolWithOptions(poolOptions(c, nil)); err != nil {
+		return err
+	}
+
+	for _, bc := range c.Backends {
+		u, err := url.Parse(bc.URL)
+		if err != nil {
+			return fmt.Errorf("invalid backend %q: %w", bc.URL, err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid backend %q: scheme and host expected", bc.URL)
+		}
+		if _, err := newHealthCheck(bc.HealthCheck); err != nil {
+			return fmt.Errorf("invalid health check of %q: %w", bc.URL, err)
+		}
+	}
+
+	return nil
+}
+
+// poolOptions converts the strategy configuration.
+func poolOptions(c *utils.Config, logger *zap.Logger) serverpool.Options {
+	return serverpool.Options{
+		Strategy:     utils.GetLBStrategy(c.Strategy),
+		HashKey:      c.ConsistentHash.Key,
+		VirtualNodes: c.ConsistentHash.VirtualNodes,
+		Logger:       logger,
+	}
+}
+
+// healthCheckOptions converts the health check schedule configuration.
+func healthCheckOptions(c *utils.Config) serverpool.HealthCheckOptions {
+	return serverpool.HealthCheckOptions{
+		Interval:     time.Second * time.Duration(c.HealthCheckInterval),
+		Timeout:      time.Second * time.Duration(c.BackendTimeout),
+		Jitter:       c.HealthCheckJitter,
+		BackoffAfter: time.Second * time.Duration(c.HealthCheckBackoffAfter),
+		MaxInterval:  time.Second * time.Duration(c.HealthCheckMaxInterval),
+	}
+}
+
+// retryPolicy converts the retry settings applied by every backend.
+func retryPolicy(c *utils.Config) backend.RetryPolicy {
+	return backend.RetryPolicy{
+		Statuses:      c.Retry.Statuses,
+		Methods:       c.Retry.Methods,
+		PerTryTimeout: time.Second * time.Duration(c.Retry.PerTryTimeout),
+		AllowRetry:    lb.AllowRetry,
+	}
+}
+
+// breakerOptions converts the circuit breaker configuration, nil when it is disabled.
+func breakerOptions(c *utils.Config, logger *zap.Logger) *backend.BreakerOptions {
+	if !c.CircuitBreaker.Enabled {
+		return nil
+	}
+	return &backend.BreakerOptions{
+		FailureRateThreshold: c.CircuitBreaker.FailureRateThreshold,
+		MinimumRequests:      c.CircuitBreaker.MinimumRequests,
+		Window:               time.Second * time.Duration(c.CircuitBreaker.Window),
+		OpenTimeout:          time.Second * time.Duration(c.CircuitBreaker.OpenTimeout),
+		HalfOpenProbes:       c.CircuitBreaker.HalfOpenProbes,
+		Logger:               logger,
+	}
+}
+
+// healthThresholds converts the rise/fall thresholds and flap damping of a backend health check.
+func healthThresholds(c utils.HealthCheckConfig) backend.HealthThresholds {
+	return backend.HealthThresholds{
+		Healthy:    c.HealthyThreshold,
+		Unhealthy:  c.UnhealthyThreshold,
+		FlapLimit:  c.FlapThreshold,
+		FlapWindow: time.Second * time.Duration(c.FlapWindow),
+		HoldDown:   time.Second * time.Duration(c.HoldDown),
+	}
+}
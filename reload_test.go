@@ -0,0 +1,157 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"load-balancer/backend"
+	"load-balancer/serverpool"
+	"load-balancer/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// busyBackend keeps a request in flight, so its drain only ends at the drain timeout.
+type busyBackend struct {
+	backend.Backend
+}
+
+func (busyBackend) GetActiveConnections() int {
+	return 1
+}
+
+// newReloader returns a reloader running the given configuration file content, with its backends in the pool.
+// Backends with a url in busy keep a request in flight.
+func newReloader(t *testing.T, config string, busy ...string) *reloader {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(config), 0o644))
+	c, err := utils.LoadLBConfig(path)
+	require.NoError(t, err, "failed to load config")
+
+	pool, err := serverpool.NewDynamicPool(poolOptions(c, zap.NewNop()))
+	require.NoError(t, err, "failed to create pool")
+
+	r := &reloader{
+		path:    path,
+		pool:    pool,
+		monitor: serverpool.NewHealthMonitor(pool, healthCheckOptions(c), zap.NewNop()),
+		newBackend: func(_ *utils.Config, bc utils.BackendConfig) (backend.Backend, error) {
+			u, err := url.Parse(bc.URL)
+			if err != nil {
+				return nil, err
+			}
+			var b backend.Backend = backend.NewBackend(u)
+			for _, s := range busy {
+				if s == bc.URL {
+					b = busyBackend{b}
+				}
+			}
+			return b, nil
+		},
+		logger: zap.NewNop(),
+	}
+	r.config.Store(c)
+
+	for _, bc := range c.Backends {
+		b, err := r.newBackend(c, bc)
+		require.NoError(t, err, "failed to create backend")
+		pool.AddBackend(b)
+	}
+	return r
+}
+
+// write replaces the configuration file of the reloader.
+func (r *reloader) write(t *testing.T, config string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(r.path, []byte(config), 0o644))
+}
+
+// urls returns the urls of the backends in the pool.
+func (r *reloader) urls() []string {
+	var urls []string
+	for _, b := range r.pool.GetBackends() {
+		urls = append(urls, b.GetURL().String())
+	}
+	return urls
+}
+
+const (
+	configAB = "lb_port: 8080\ndrain_timeout: 60\nbackends: [\"http://127.0.0.1:8081\", \"http://127.0.0.1:8082\"]\n"
+	configA  = "lb_port: 8080\ndrain_timeout: 60\nbackends: [\"http://127.0.0.1:8081\"]\n"
+)
+
+// Test an invalid config is rejected as a whole and the running one keeps serving
+func TestReload_InvalidConfig(t *testing.T) {
+	r := newReloader(t, configAB)
+	running := r.config.Load()
+
+	r.write(t, "lb_port: 8080\nstrategy: fastest\nbackends: [\"http://127.0.0.1:8081\"]\n")
+	r.reload("test")
+	assert.Same(t, running, r.config.Load())
+
+	r.write(t, "lb_port: 8080\nbackends: [\"127.0.0.1:8081\"]\n")
+	r.reload("test")
+	assert.Same(t, running, r.config.Load())
+	assert.Equal(t, []string{"http://127.0.0.1:8081", "http://127.0.0.1:8082"}, r.urls())
+}
+
+// Test a backend removed from the file is drained, then removed once idle
+func TestReload_RemovedBackendDrained(t *testing.T) {
+	r := newReloader(t, configAB)
+
+	r.write(t, configA)
+	r.reload("test")
+
+	assert.Eventually(t, func() bool { return r.pool.GetServerPoolSize() == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"http://127.0.0.1:8081"}, r.urls())
+}
+
+// Test a backend added back to the file before its drain finished keeps its place in the pool
+func TestReload_ReaddedBackendKept(t *testing.T) {
+	r := newReloader(t, configAB, "http://127.0.0.1:8082")
+	drained := r.pool.GetBackends()[1]
+
+	r.write(t, configA)
+	r.reload("test")
+	require.True(t, drained.IsDraining())
+
+	r.write(t, configAB)
+	r.reload("test")
+	assert.False(t, drained.IsDraining())
+	assert.False(t, r.pool.CancelDrain(drained), "the pending drain is cancelled")
+	assert.Equal(t, []backend.Backend{r.pool.GetBackends()[0], drained}, r.pool.GetBackends())
+}
+
+// Test backends added through the admin API are left alone by a reload
+func TestReload_AdminBackendKept(t *testing.T) {
+	r := newReloader(t, configAB)
+
+	u, err := url.Parse("http://127.0.0.1:8083")
+	require.NoError(t, err, "failed to parse url")
+	added := backend.NewBackend(u)
+	r.pool.AddBackend(added)
+
+	r.reload("test")
+	assert.False(t, added.IsDraining())
+	assert.Contains(t, r.pool.GetBackends(), added)
+}
+
+// Test the strategy is switched and restart-only settings are reported
+func TestReload_StrategyAndRestartSettings(t *testing.T) {
+	r := newReloader(t, configAB)
+	prev := r.config.Load()
+
+	r.write(t, "lb_port: 9090\nstrategy: least-connection\ndrain_timeout: 60\nbackends: [\"http://127.0.0.1:8081\", \"http://127.0.0.1:8082\"]\n")
+	r.reload("test")
+
+	assert.Equal(t, utils.LeastConnected, r.pool.GetOptions().Strategy)
+	assert.Len(t, r.pool.GetBackends(), 2)
+	assert.Equal(t, []string{"lb_port"}, restartSettings(prev, r.config.Load()))
+}
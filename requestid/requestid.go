@@ -0,0 +1,71 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"go.uber.org/zap"
+)
+
+// Header carries the request id to backends and back to clients.
+const Header = "X-Request-ID"
+
+// maxLength is the longest incoming request id kept.
+const maxLength = 128
+
+type contextKey struct{}
+
+// New returns a random version 4 UUID.
+func New() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+
+	var s [36]byte
+	hex.Encode(s[0:8], b[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], b[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], b[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], b[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:], b[10:])
+	return string(s[:])
+}
+
+// Valid reports whether an incoming request id can be kept: 1 to 128 visible ASCII characters,
+// so it cannot break log lines or headers.
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// NewContext returns a context carrying the request id.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request id of the context, empty if there is none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Logger returns the logger with the request id of the context added to every line.
+func Logger(ctx context.Context, logger *zap.Logger) *zap.Logger {
+	id := FromContext(ctx)
+	if id == "" {
+		return logger
+	}
+	return logger.With(zap.String("request_id", id))
+}
@@ -0,0 +1,50 @@
+package requestid
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// Test generated ids are unique version 4 UUIDs accepted by Valid
+func TestNew(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	seen := make(map[string]struct{})
+	for i := 0; i < 100; i++ {
+		id := New()
+		assert.Regexp(t, uuid, id)
+		assert.True(t, Valid(id))
+		assert.NotContains(t, seen, id)
+		seen[id] = struct{}{}
+	}
+}
+
+// Test incoming ids are kept only if they cannot break headers or log lines
+func TestValid(t *testing.T) {
+	for _, id := range []string{"abc", "req-42", "6f1c0c7e-3a6b-4c7e-9d2f-0a1b2c3d4e5f", "a/b:c=d+e_f.g", strings.Repeat("x", 128)} {
+		assert.True(t, Valid(id), id)
+	}
+	for _, id := range []string{"", "a b", "line\nbreak", "tab\t", "café", strings.Repeat("x", 129)} {
+		assert.False(t, Valid(id), id)
+	}
+}
+
+// Test the logger adds the request id of the context to every line
+func TestLogger(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+
+	Logger(context.Background(), logger).Info("without id")
+	Logger(NewContext(context.Background(), "req-42"), logger).Info("with id")
+
+	entries := logs.All()
+	assert.Len(t, entries, 2)
+	assert.NotContains(t, entries[0].ContextMap(), "request_id")
+	assert.Equal(t, "req-42", entries[1].ContextMap()["request_id"])
+}
@@ -0,0 +1,99 @@
+package serverpool
+
+import (
+	"load-balancer/backend"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// backendList holds the backends of a pool with the listing, removal and drain logic every strategy shares.
+// Strategies embed it and keep their own selection state in sync through onRemove.
+type backendList struct {
+	backends   []backend.Backend   // slice of servers
+	backendMap map[string]struct{} // track urls
+	mux        sync.RWMutex        // RWMutex in read-heavy scenarios (lb has many reads)
+	logger     *zap.Logger         // drains are logged here
+	drains     drains              // pending drains of the pool
+	onRemove   func(i int)         // drops the strategy state of the backend at index i, under mux
+}
+
+// init prepares an empty list, onRemove may be nil.
+func (l *backendList) init(logger *zap.Logger, onRemove func(i int)) {
+	l.backends = make([]backend.Backend, 0)
+	l.backendMap = make(map[string]struct{})
+	l.logger = logger
+	l.onRemove = onRemove
+}
+
+// GetBackends returns a copy of all backend servers in the pool.
+func (l *backendList) GetBackends() []backend.Backend {
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+
+	copied := make([]backend.Backend, len(l.backends))
+	copy(copied, l.backends)
+
+	return copied
+}
+
+// RemoveBackend removes the backend server with the given url from the pool.
+// In-flight requests to the backend are not interrupted. Returns false if the url is not in the pool.
+func (l *backendList) RemoveBackend(url string) bool {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return l.removeBackend(url, nil)
+}
+
+// RemoveBackendIf removes the backend from the pool if it is still the one registered under its url.
+// Returns false if another backend took its url or it is not in the pool.
+func (l *backendList) RemoveBackendIf(b backend.Backend) bool {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return l.removeBackend(b.GetURL().String(), b)
+}
+
+// removeBackend removes the backend with the given url, if it is want when want is not nil.
+// The caller holds the lock.
+func (l *backendList) removeBackend(url string, want backend.Backend) bool {
+	if _, exists := l.backendMap[url]; !exists {
+		return false
+	}
+
+	for i, b := range l.backends {
+		if b.GetURL().String() != url {
+			continue
+		}
+		if want != nil && b != want {
+			return false
+		}
+		l.backends = append(l.backends[:i], l.backends[i+1:]...)
+		if l.onRemove != nil {
+			l.onRemove(i)
+		}
+		break
+	}
+	delete(l.backendMap, url)
+	return true
+}
+
+// DrainBackend stops sending new requests to the backend server with the given url and removes it
+// from the pool once its in-flight requests finished or the timeout passed.
+// Returns false if the url is not in the pool.
+func (l *backendList) DrainBackend(url string, timeout time.Duration) bool {
+	return l.drains.drain(l, l.logger, url, timeout)
+}
+
+// CancelDrain stops the pending drain of the backend, which then keeps its place in the pool
+// and gets new requests again. Returns false if the backend was not draining.
+func (l *backendList) CancelDrain(b backend.Backend) bool {
+	return l.drains.cancel(b)
+}
+
+// GetServerPoolSize returns the current number of servers in the pool.
+func (l *backendList) GetServerPoolSize() int {
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+	return len(l.backends)
+}
@@ -0,0 +1,186 @@
+package serverpool
+
+import (
+	"fmt"
+	"hash/fnv"
+	"load-balancer/backend"
+	"load-balancer/forwarded"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const defaultVirtualNodes = 100
+
+// ringPoint is a single virtual node on the hash ring.
+type ringPoint struct {
+	hash  uint64
+	index int // index into backends
+}
+
+// chServerPool implements the ServerPool interface with consistent hashing strategy.
+// Every backend owns a number of virtual nodes on a hash ring, and a request is sent to the
+// first alive backend found clockwise from the hash of its key. Adding or removing a backend
+// only remaps the keys adjacent to its virtual nodes (about 1/N of all keys).
+type chServerPool struct {
+	backendList
+	ring         []ringPoint // virtual nodes sorted by hash
+	virtualNodes int         // virtual nodes per backend
+	key          func(*http.Request) string
+}
+
+// newConsistentHashServerPool creates a consistent hash pool.
+// hashKey selects the request key and defaults to the client IP.
+func newConsistentHashServerPool(hashKey string, virtualNodes int) (*chServerPool, error) {
+	key, err := parseHashKey(hashKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if virtualNodes <= 0 {
+		virtualNodes = defaultVirtualNodes
+	}
+
+	s := &chServerPool{
+		ring:         make([]ringPoint, 0),
+		virtualNodes: virtualNodes,
+		key:          key,
+	}
+	s.init(zap.NewNop(), s.removed)
+	return s, nil
+}
+
+// parseHashKey returns a function extracting the hash key from a request.
+// Supported keys are "ip", "path", "header:<name>" and "cookie:<name>".
+// Header and cookie keys fall back to the client IP when the request does not carry them.
+func parseHashKey(hashKey string) (func(*http.Request) string, error) {
+	kind, name, _ := strings.Cut(hashKey, ":")
+
+	switch kind {
+	case "", "ip":
+		return forwarded.ClientIP, nil
+	case "path":
+		return func(r *http.Request) string { return r.URL.Path }, nil
+	case "header":
+		if name == "" {
+			return nil, fmt.Errorf("invalid hash key %q: header name expected", hashKey)
+		}
+		return func(r *http.Request) string {
+			if v := r.Header.Get(name); v != "" {
+				return v
+			}
+			return forwarded.ClientIP(r)
+		}, nil
+	case "cookie":
+		if name == "" {
+			return nil, fmt.Errorf("invalid hash key %q: cookie name expected", hashKey)
+		}
+		return func(r *http.Request) string {
+			if c, err := r.Cookie(name); err == nil && c.Value != "" {
+				return c.Value
+			}
+			return forwarded.ClientIP(r)
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid hash key %q", hashKey)
+	}
+}
+
+// hashString hashes s with 64-bit FNV-1a followed by the murmur3 finalizer.
+// FNV alone clusters similar inputs (like "host#1", "host#2"), the finalizer spreads them over the ring.
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+
+	return mix64(h.Sum64())
+}
+
+// mix64 is the murmur3 64-bit finalizer, it spreads similar inputs over the whole range.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// GetNextValidPeer returns the alive backend owning the request key on the hash ring.
+// Dead backends are skipped by walking clockwise to the next virtual node.
+// Returns nil if there is no alive backend found or the request is nil.
+func (s *chServerPool) GetNextValidPeer(r *http.Request) backend.Backend {
+	if r == nil {
+		return nil
+	}
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	n := len(s.ring)
+	if n == 0 {
+		return nil
+	}
+
+	h := hashString(s.key(r))
+	start := sort.Search(n, func(i int) bool { return s.ring[i].hash >= h })
+
+	// A backend in slow start only owns the share of its keys given by its slow start factor,
+	// picked from the key hash so a key keeps its backend while the factor grows
+	share := float64(mix64(h)>>11) / (1 << 53)
+
+	var fallback backend.Backend
+	for i := 0; i < n; i++ {
+		peer := s.backends[s.ring[(start+i)%n].index]
+		if !IsSelectable(r, peer) {
+			continue
+		}
+		if share < peer.GetSlowStartFactor() {
+			return peer
+		}
+		if fallback == nil {
+			fallback = peer
+		}
+	}
+
+	return fallback
+}
+
+// AddBackend adds new backend server to the consistent hash pool and places its virtual nodes on the ring.
+func (s *chServerPool) AddBackend(b backend.Backend) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	u := b.GetURL().String()
+	if _, exists := s.backendMap[u]; exists {
+		return
+	}
+	s.backends = append(s.backends, b)
+	s.backendMap[u] = struct{}{}
+
+	index := len(s.backends) - 1
+	for i := 0; i < s.virtualNodes; i++ {
+		s.ring = append(s.ring, ringPoint{
+			hash:  hashString(u + "#" + strconv.Itoa(i)),
+			index: index,
+		})
+	}
+	sort.Slice(s.ring, func(i, j int) bool { return s.ring[i].hash < s.ring[j].hash })
+}
+
+// removed drops the virtual nodes of the backend removed at index i and shifts the indexes of the backends after it.
+// The caller holds the lock.
+func (s *chServerPool) removed(i int) {
+	ring := s.ring[:0]
+	for _, p := range s.ring {
+		switch {
+		case p.index == i:
+			continue
+		case p.index > i:
+			p.index--
+		}
+		ring = append(ring, p)
+	}
+	s.ring = ring
+}
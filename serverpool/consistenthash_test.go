@@ -0,0 +1,304 @@
+package serverpool
+
+import (
+	"context"
+	"load-balancer/backend"
+	"load-balancer/forwarded"
+	"load-balancer/utils"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hashURLs are the backend urls of the consistent hash tests, a pool of n backends takes the first n.
+var hashURLs = []string{
+	"http://10.0.0.1:8080",
+	"http://10.0.0.2:8080",
+	"http://10.0.0.3:8080",
+	"http://10.0.0.4:8080",
+	"http://10.0.0.5:8080",
+	"http://10.0.0.6:8080",
+	"http://10.0.0.7:8080",
+	"http://10.0.0.8:8080",
+	"http://10.0.0.9:8080",
+	"http://10.0.0.10:8080",
+}
+
+func requestFrom(ip string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = ip + ":54321"
+	return req
+}
+
+// Test the same client IP always maps to the same backend
+func TestConsistentHash_SameKeySamePeer(t *testing.T) {
+	sp, err := NewServerPoolWithOptions(Options{Strategy: utils.ConsistentHash, HashKey: "ip"})
+	require.NoError(t, err, "failed to create server pool")
+	addBackends(t, sp, hashURLs[:5]...)
+
+	for i := 0; i < 50; i++ {
+		ip := "192.168.1." + strconv.Itoa(i)
+		first := sp.GetNextValidPeer(requestFrom(ip))
+		require.NotNil(t, first)
+
+		for j := 0; j < 5; j++ {
+			assert.Equal(t, first, sp.GetNextValidPeer(requestFrom(ip)))
+		}
+	}
+}
+
+// Test the ip key hashes the client resolved through trusted proxies, not the proxy connection
+func TestConsistentHash_ForwardedClient(t *testing.T) {
+	sp, err := NewServerPoolWithOptions(Options{Strategy: utils.ConsistentHash, HashKey: "ip"})
+	require.NoError(t, err, "failed to create server pool")
+	addBackends(t, sp, hashURLs[:5]...)
+	policy := forwarded.Policy{TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}
+
+	for i := 0; i < 20; i++ {
+		client := "192.168.1." + strconv.Itoa(i)
+		want := sp.GetNextValidPeer(requestFrom(client))
+
+		for _, proxy := range []string{"10.0.0.1", "10.0.0.2"} {
+			req := requestFrom(proxy)
+			req.Header.Set("X-Forwarded-For", client)
+			req = req.WithContext(forwarded.NewContext(context.Background(), policy.Resolve(req)))
+			assert.Equal(t, want, sp.GetNextValidPeer(req), client)
+		}
+	}
+}
+
+// Test keys are spread across all backends
+func TestConsistentHash_Distribution(t *testing.T) {
+	sp, err := NewServerPoolWithOptions(Options{Strategy: utils.ConsistentHash, HashKey: "ip"})
+	require.NoError(t, err, "failed to create server pool")
+	backends := addBackends(t, sp, hashURLs[:4]...)
+
+	counts := map[backend.Backend]int{}
+	for i := 0; i < 4000; i++ {
+		ip := "172.16." + strconv.Itoa(i/256) + "." + strconv.Itoa(i%256)
+		counts[sp.GetNextValidPeer(requestFrom(ip))]++
+	}
+
+	for _, b := range backends {
+		// Each backend should get a reasonable share of the 1000 expected keys
+		assert.Greater(t, counts[b], 500, b.GetURL().String())
+		assert.Less(t, counts[b], 1500, b.GetURL().String())
+	}
+}
+
+// Test adding a backend only remaps about 1/N of the keys
+func TestConsistentHash_MinimalRemap(t *testing.T) {
+	sp, err := NewServerPoolWithOptions(Options{Strategy: utils.ConsistentHash, HashKey: "path"})
+	require.NoError(t, err, "failed to create server pool")
+	addBackends(t, sp, hashURLs[:10]...)
+
+	keys := 5000
+	before := make([]backend.Backend, keys)
+	for i := 0; i < keys; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/item/"+strconv.Itoa(i), nil)
+		before[i] = sp.GetNextValidPeer(req)
+	}
+
+	u, err := url.Parse("http://10.0.0.99:8080")
+	require.NoError(t, err, "failed to parse url")
+	added := backend.NewBackend(u)
+	sp.AddBackend(added)
+
+	moved := 0
+	for i := 0; i < keys; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/item/"+strconv.Itoa(i), nil)
+		peer := sp.GetNextValidPeer(req)
+		if peer != before[i] {
+			// Keys may only move to the new backend
+			assert.Equal(t, added, peer)
+			moved++
+		}
+	}
+
+	// Expect roughly 1/11 of the keys to move
+	assert.Greater(t, moved, keys/30)
+	assert.Less(t, moved, keys/5)
+}
+
+// Test a dead backend's keys move to other backends and come back after revival
+func TestConsistentHash_SkipDeadBackend(t *testing.T) {
+	sp, err := NewServerPoolWithOptions(Options{Strategy: utils.ConsistentHash, HashKey: "ip"})
+	require.NoError(t, err, "failed to create server pool")
+	addBackends(t, sp, hashURLs[:3]...)
+
+	req := requestFrom("203.0.113.7")
+	owner := sp.GetNextValidPeer(req)
+	require.NotNil(t, owner)
+
+	owner.SetAlive(false)
+	fallback := sp.GetNextValidPeer(req)
+	require.NotNil(t, fallback)
+	assert.NotEqual(t, owner, fallback)
+
+	owner.SetAlive(true)
+	assert.Equal(t, owner, sp.GetNextValidPeer(req))
+}
+
+// Test all backends dead
+func TestConsistentHash_AllDead(t *testing.T) {
+	sp, err := NewServerPoolWithOptions(Options{Strategy: utils.ConsistentHash, HashKey: "ip"})
+	require.NoError(t, err, "failed to create server pool")
+	backends := addBackends(t, sp, hashURLs[:3]...)
+	for _, b := range backends {
+		b.SetAlive(false)
+	}
+
+	assert.Nil(t, sp.GetNextValidPeer(requestFrom("203.0.113.7")))
+}
+
+// Test empty pool
+func TestConsistentHash_EmptyPool(t *testing.T) {
+	sp, err := NewServerPoolWithOptions(Options{Strategy: utils.ConsistentHash, HashKey: "ip"})
+	require.NoError(t, err, "failed to create server pool")
+
+	assert.Nil(t, sp.GetNextValidPeer(requestFrom("203.0.113.7")))
+}
+
+// Test header and cookie keys, including the fallback to the client IP
+func TestConsistentHash_HeaderAndCookieKeys(t *testing.T) {
+	headerPool, err := NewServerPoolWithOptions(Options{Strategy: utils.ConsistentHash, HashKey: "header:X-User"})
+	require.NoError(t, err, "failed to create server pool")
+	addBackends(t, headerPool, hashURLs[:5]...)
+
+	req := requestFrom("198.51.100.1")
+	req.Header.Set("X-User", "alice")
+	peer := headerPool.GetNextValidPeer(req)
+
+	for i := 0; i < 20; i++ {
+		// Different client, same user header
+		other := requestFrom("198.51.100." + strconv.Itoa(i+2))
+		other.Header.Set("X-User", "alice")
+		assert.Equal(t, peer, headerPool.GetNextValidPeer(other))
+	}
+
+	// Missing header falls back to the client IP
+	noHeader := requestFrom("198.51.100.1")
+	assert.Equal(t, headerPool.GetNextValidPeer(requestFrom("198.51.100.1")), headerPool.GetNextValidPeer(noHeader))
+
+	cookiePool, err := NewServerPoolWithOptions(Options{Strategy: utils.ConsistentHash, HashKey: "cookie:session"})
+	require.NoError(t, err, "failed to create server pool")
+	addBackends(t, cookiePool, hashURLs[:5]...)
+
+	req = requestFrom("198.51.100.1")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "abc123"})
+	peer = cookiePool.GetNextValidPeer(req)
+
+	for i := 0; i < 20; i++ {
+		other := requestFrom("198.51.100." + strconv.Itoa(i+2))
+		other.AddCookie(&http.Cookie{Name: "session", Value: "abc123"})
+		assert.Equal(t, peer, cookiePool.GetNextValidPeer(other))
+	}
+}
+
+// Test invalid hash key configuration
+func TestConsistentHash_InvalidKey(t *testing.T) {
+	for _, key := range []string{"header:", "cookie:", "query"} {
+		sp, err := NewServerPoolWithOptions(Options{Strategy: utils.ConsistentHash, HashKey: key})
+		assert.Nil(t, sp)
+		assert.Error(t, err, key)
+	}
+}
+
+// Test concurrent access
+func TestConsistentHash_ConcurrentAccess(t *testing.T) {
+	sp, err := NewServerPool(utils.ConsistentHash)
+	require.NoError(t, err, "failed to create server pool")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			u, err := url.Parse("http://127.0.0.1:808" + strconv.Itoa(i))
+			require.NoError(t, err, "failed to parse url")
+			sp.AddBackend(backend.NewBackend(u))
+		}(i)
+	}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sp.GetNextValidPeer(requestFrom("10.1.1." + strconv.Itoa(i)))
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 5, sp.GetServerPoolSize())
+}
+
+// Test attempting to add duplicate url
+func TestConsistentHash_AddDuplicate(t *testing.T) {
+	sp, err := NewServerPoolWithOptions(Options{Strategy: utils.ConsistentHash, HashKey: "ip"})
+	require.NoError(t, err, "failed to create server pool")
+	backends := addBackends(t, sp, hashURLs[:1]...)
+
+	sp.AddBackend(backends[0]) // should be ignored
+
+	assert.Len(t, sp.GetBackends(), 1)
+}
+
+// Test a backend in slow start owns a small share of its keys, and keys keep their backend
+func TestConsistentHash_SlowStart(t *testing.T) {
+	sp, err := NewServerPool(utils.ConsistentHash)
+	require.NoError(t, err, "failed to create server pool")
+
+	u, err := url.Parse("http://127.0.0.1:8081")
+	require.NoError(t, err, "failed to parse url")
+	warming := backend.NewBackendWithOptions(u, backend.Options{SlowStart: time.Hour})
+	warming.SetAlive(false)
+	warming.SetAlive(true)
+	sp.AddBackend(warming)
+	addBackends(t, sp, "http://127.0.0.1:8082")
+
+	picked := 0
+	for i := 0; i < 1000; i++ {
+		ip := "10.1." + strconv.Itoa(i/256) + "." + strconv.Itoa(i%256)
+		peer := sp.GetNextValidPeer(requestFrom(ip))
+		assert.Equal(t, peer, sp.GetNextValidPeer(requestFrom(ip)), "key must keep its backend")
+		if peer == warming {
+			picked++
+		}
+	}
+
+	// It would own about half the keys at full share
+	assert.Greater(t, picked, 0)
+	assert.Less(t, picked, 150)
+}
+
+// Test removing a backend only remaps the keys it owned
+func TestConsistentHash_RemoveOnlyRemapsOwnKeys(t *testing.T) {
+	sp, err := NewServerPoolWithOptions(Options{Strategy: utils.ConsistentHash, HashKey: "ip"})
+	require.NoError(t, err, "failed to create server pool")
+	backends := addBackends(t, sp, hashURLs[:5]...)
+
+	before := map[string]backend.Backend{}
+	for i := 0; i < 500; i++ {
+		ip := "10.2." + strconv.Itoa(i/256) + "." + strconv.Itoa(i%256)
+		before[ip] = sp.GetNextValidPeer(requestFrom(ip))
+	}
+
+	require.True(t, sp.RemoveBackend(backends[2].GetURL().String()))
+
+	for ip, owner := range before {
+		peer := sp.GetNextValidPeer(requestFrom(ip))
+		if owner == backends[2] {
+			assert.NotEqual(t, backends[2], peer)
+			continue
+		}
+		assert.Equal(t, owner, peer, "key %s moved", ip)
+	}
+}
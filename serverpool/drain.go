@@ -0,0 +1,121 @@
+package serverpool
+
+import (
+	"load-balancer/backend"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// drainPollInterval is how often a draining backend is checked for in-flight requests.
+const drainPollInterval = 100 * time.Millisecond
+
+// drainable is the part of a pool a drain works on.
+type drainable interface {
+	GetBackends() []backend.Backend
+	RemoveBackendIf(b backend.Backend) bool
+}
+
+// drains holds the cancel channel of every pending drain of a pool, by backend.
+// Its lock is held while a drained backend is removed, so a drain cancelled in time never removes it.
+type drains struct {
+	mux     sync.Mutex
+	pending map[backend.Backend]chan struct{}
+}
+
+// drain marks the backend with the given url as draining and removes it from the pool
+// once its in-flight requests finished or the timeout passed. It returns without waiting.
+// A backend already draining keeps its pending drain.
+// Returns false if the url is not in the pool.
+func (d *drains) drain(s drainable, logger *zap.Logger, url string, timeout time.Duration) bool {
+	var b backend.Backend
+	for _, peer := range s.GetBackends() {
+		if peer.GetURL().String() == url {
+			b = peer
+			break
+		}
+	}
+	if b == nil {
+		return false
+	}
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if _, ok := d.pending[b]; ok {
+		return true
+	}
+	if d.pending == nil {
+		d.pending = make(map[backend.Backend]chan struct{})
+	}
+	cancel := make(chan struct{})
+	d.pending[b] = cancel
+
+	b.SetDraining(true)
+	logger.Info("draining backend", zap.String("url", url), zap.Duration("timeout", timeout))
+
+	go func() {
+		cancelled := !waitIdle(b, timeout, cancel)
+
+		d.mux.Lock()
+		defer d.mux.Unlock()
+		select {
+		case <-cancel:
+			cancelled = true
+		default:
+		}
+		if cancelled {
+			logger.Info("backend drain cancelled", zap.String("url", url))
+			return
+		}
+		delete(d.pending, b)
+
+		// The backend may have been removed, and another one added under its url, meanwhile
+		if s.RemoveBackendIf(b) {
+			logger.Info(
+				"drained backend removed",
+				zap.String("url", url),
+				zap.Int("in_flight", b.GetActiveConnections()),
+			)
+		}
+	}()
+
+	return true
+}
+
+// waitIdle waits until the backend has no request in flight or the timeout passed.
+// Returns false if the drain was cancelled first.
+func waitIdle(b backend.Backend, timeout time.Duration, cancel <-chan struct{}) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	t := time.NewTicker(drainPollInterval)
+	defer t.Stop()
+
+	for b.GetActiveConnections() > 0 {
+		select {
+		case <-t.C:
+		case <-deadline.C:
+			return true
+		case <-cancel:
+			return false
+		}
+	}
+	return true
+}
+
+// cancel stops the pending drain of the backend and clears its draining flag.
+// Returns false if the backend was not draining.
+func (d *drains) cancel(b backend.Backend) bool {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	cancel, ok := d.pending[b]
+	if !ok {
+		return false
+	}
+	close(cancel)
+	delete(d.pending, b)
+	b.SetDraining(false)
+	return true
+}
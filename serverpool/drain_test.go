@@ -0,0 +1,135 @@
+package serverpool
+
+import (
+	"load-balancer/backend"
+	"load-balancer/utils"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// busyBackend keeps a request in flight until finished is set.
+type busyBackend struct {
+	backend.Backend
+	finished atomic.Bool
+}
+
+func (b *busyBackend) GetActiveConnections() int {
+	if b.finished.Load() {
+		return 0
+	}
+	return 1
+}
+
+// Test a draining backend gets no new requests and is removed once its in-flight requests finished
+func TestDrainBackend_WaitsForInFlight(t *testing.T) {
+	sp, err := NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+	u, err := url.Parse("http://127.0.0.1:8081")
+	require.NoError(t, err, "failed to parse url")
+	busy := &busyBackend{Backend: backend.NewBackend(u)}
+	sp.AddBackend(busy)
+	idle := addBackends(t, sp, "http://127.0.0.1:8082")[0]
+
+	require.True(t, sp.DrainBackend(busy.GetURL().String(), time.Minute))
+	assert.True(t, busy.IsDraining())
+
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, idle, sp.GetNextValidPeer(nil))
+	}
+
+	time.Sleep(2 * drainPollInterval)
+	assert.Equal(t, 2, sp.GetServerPoolSize(), "in-flight request still running")
+
+	busy.finished.Store(true)
+	assert.Eventually(t, func() bool { return sp.GetServerPoolSize() == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []backend.Backend{idle}, sp.GetBackends())
+}
+
+// Test a draining backend is removed after the timeout even with requests in flight
+func TestDrainBackend_Timeout(t *testing.T) {
+	sp, err := NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+	u, err := url.Parse("http://127.0.0.1:8081")
+	require.NoError(t, err, "failed to parse url")
+	busy := &busyBackend{Backend: backend.NewBackend(u)}
+	sp.AddBackend(busy)
+	idle := addBackends(t, sp, "http://127.0.0.1:8082")[0]
+
+	require.True(t, sp.DrainBackend(busy.GetURL().String(), 50*time.Millisecond))
+
+	assert.Eventually(t, func() bool { return sp.GetServerPoolSize() == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []backend.Backend{idle}, sp.GetBackends())
+}
+
+// Test draining an unknown url reports it
+func TestDrainBackend_Unknown(t *testing.T) {
+	sp, err := NewServerPool(utils.LeastConnected)
+	require.NoError(t, err, "failed to create server pool")
+
+	assert.False(t, sp.DrainBackend("http://127.0.0.1:9999", time.Second))
+}
+
+// Test a cancelled drain keeps the backend in the pool and sends it new requests again
+func TestDrainBackend_Cancel(t *testing.T) {
+	sp, err := NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+	u, err := url.Parse("http://127.0.0.1:8081")
+	require.NoError(t, err, "failed to parse url")
+	busy := &busyBackend{Backend: backend.NewBackend(u)}
+	sp.AddBackend(busy)
+	addBackends(t, sp, "http://127.0.0.1:8082")
+
+	require.True(t, sp.DrainBackend(busy.GetURL().String(), 50*time.Millisecond))
+	assert.True(t, sp.DrainBackend(busy.GetURL().String(), 50*time.Millisecond), "a second drain keeps the pending one")
+	require.True(t, sp.(*roundRobinServerPool).CancelDrain(busy))
+	assert.False(t, busy.IsDraining())
+	assert.False(t, sp.(*roundRobinServerPool).CancelDrain(busy), "nothing left to cancel")
+
+	time.Sleep(4 * drainPollInterval)
+	assert.Equal(t, 2, sp.GetServerPoolSize())
+	assert.Contains(t, sp.GetBackends(), busy)
+}
+
+// Test a backend added again under the url of a draining one is not removed by the drain
+func TestDrainBackend_ReplacedBackend(t *testing.T) {
+	sp, err := NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+	u, err := url.Parse("http://127.0.0.1:8081")
+	require.NoError(t, err, "failed to parse url")
+	busy := &busyBackend{Backend: backend.NewBackend(u)}
+	sp.AddBackend(busy)
+	addBackends(t, sp, "http://127.0.0.1:8082")
+
+	require.True(t, sp.DrainBackend(busy.GetURL().String(), 50*time.Millisecond))
+	require.True(t, sp.RemoveBackend(busy.GetURL().String()))
+	replacement := backend.NewBackend(busy.GetURL())
+	sp.AddBackend(replacement)
+
+	time.Sleep(4 * drainPollInterval)
+	assert.Contains(t, sp.GetBackends(), replacement)
+	assert.False(t, sp.RemoveBackendIf(busy))
+}
+
+// Test the drains of a pool are its own, another pool holding the same backend cannot cancel them
+func TestDrainBackend_PerPool(t *testing.T) {
+	sp, err := NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+	u, err := url.Parse("http://127.0.0.1:8081")
+	require.NoError(t, err, "failed to parse url")
+	busy := &busyBackend{Backend: backend.NewBackend(u)}
+	sp.AddBackend(busy)
+	addBackends(t, sp, "http://127.0.0.1:8082")
+
+	other, err := NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+	other.AddBackend(busy)
+
+	require.True(t, sp.DrainBackend(busy.GetURL().String(), time.Minute))
+	assert.False(t, other.(*roundRobinServerPool).CancelDrain(busy))
+	assert.True(t, sp.(*roundRobinServerPool).CancelDrain(busy))
+}
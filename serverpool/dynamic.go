@@ -0,0 +1,145 @@
+package serverpool
+
+import (
+	"load-balancer/backend"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// poolRef pairs a server pool with the options it was created from.
+type poolRef struct {
+	pool ServerPool
+	opts Options
+}
+
+// DynamicPool is a ServerPool whose strategy can be switched at runtime.
+// Selection goes through an atomic pointer to the current pool, so switching never blocks requests,
+// and the backends with their connection counts carry over to the new pool.
+type DynamicPool struct {
+	mux     sync.Mutex // serializes changes, selection does not take it
+	current atomic.Pointer[poolRef]
+	logger  *zap.Logger           // drains are logged here, from the options of NewDynamicPool
+	removed func(backend.Backend) // called with every backend removed from the pool, under mux
+	drains  drains                // pending drains, kept across strategy switches
+}
+
+// NewDynamicPool creates a dynamic pool with the strategy from the provided options.
+// Returns an error if the strategy is unsupported.
+func NewDynamicPool(opts Options) (*DynamicPool, error) {
+	pool, err := NewServerPoolWithOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &DynamicPool{logger: opts.Logger}
+	if d.logger == nil {
+		d.logger = zap.NewNop()
+	}
+	d.current.Store(&poolRef{pool: pool, opts: opts})
+	return d, nil
+}
+
+// GetOptions returns the options of the current pool.
+func (d *DynamicPool) GetOptions() Options {
+	return d.current.Load().opts
+}
+
+// SetOptions switches to a pool created from the provided options, moving every backend over.
+// Requests in flight keep their backend. Returns an error, keeping the current pool, if the strategy is unsupported.
+func (d *DynamicPool) SetOptions(opts Options) error {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	pool, err := NewServerPoolWithOptions(opts)
+	if err != nil {
+		return err
+	}
+	for _, b := range d.current.Load().pool.GetBackends() {
+		pool.AddBackend(b)
+	}
+
+	d.current.Store(&poolRef{pool: pool, opts: opts})
+	return nil
+}
+
+// GetBackends returns a copy of all backend servers in the current pool.
+func (d *DynamicPool) GetBackends() []backend.Backend {
+	return d.current.Load().pool.GetBackends()
+}
+
+// GetNextValidPeer selects a backend with the strategy of the current pool.
+func (d *DynamicPool) GetNextValidPeer(r *http.Request) backend.Backend {
+	return d.current.Load().pool.GetNextValidPeer(r)
+}
+
+// AddBackend adds new backend server to the current pool.
+func (d *DynamicPool) AddBackend(b backend.Backend) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	d.current.Load().pool.AddBackend(b)
+}
+
+// SetRemoveObserver registers a function called with every backend removed from the pool,
+// at once or at the end of a drain, so state kept per backend can be dropped.
+func (d *DynamicPool) SetRemoveObserver(fn func(backend.Backend)) {
+	d.mux.Lock()
+	d.removed = fn
+	d.mux.Unlock()
+}
+
+// RemoveBackend removes the backend server with the given url from the current pool.
+// Returns false if the url is not in the pool.
+func (d *DynamicPool) RemoveBackend(url string) bool {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	pool := d.current.Load().pool
+	for _, b := range pool.GetBackends() {
+		if b.GetURL().String() == url {
+			return d.removeBackend(pool, b)
+		}
+	}
+	return false
+}
+
+// RemoveBackendIf removes the backend from the current pool if it is still the one registered under its url.
+// Returns false if another backend took its url or it is not in the pool.
+func (d *DynamicPool) RemoveBackendIf(b backend.Backend) bool {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	return d.removeBackend(d.current.Load().pool, b)
+}
+
+// removeBackend removes the backend from the pool and reports it to the remove observer.
+// The caller holds mux.
+func (d *DynamicPool) removeBackend(pool ServerPool, b backend.Backend) bool {
+	if !pool.RemoveBackendIf(b) {
+		return false
+	}
+	if d.removed != nil {
+		d.removed(b)
+	}
+	return true
+}
+
+// DrainBackend stops sending new requests to the backend server with the given url and removes it
+// from the pool once its in-flight requests finished or the timeout passed, even if the strategy was switched meanwhile.
+// Returns false if the url is not in the pool.
+func (d *DynamicPool) DrainBackend(url string, timeout time.Duration) bool {
+	return d.drains.drain(d, d.logger, url, timeout)
+}
+
+// CancelDrain stops the pending drain of the backend, which then keeps its place in the pool
+// and gets new requests again. Returns false if the backend was not draining.
+func (d *DynamicPool) CancelDrain(b backend.Backend) bool {
+	return d.drains.cancel(b)
+}
+
+// GetServerPoolSize returns the current number of servers in the pool.
+func (d *DynamicPool) GetServerPoolSize() int {
+	return d.current.Load().pool.GetServerPoolSize()
+}
@@ -0,0 +1,96 @@
+package serverpool
+
+import (
+	"load-balancer/backend"
+	"load-balancer/utils"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test switching the strategy keeps the backends and changes the selection
+func TestDynamicPool_SetOptions(t *testing.T) {
+	d, err := NewDynamicPool(Options{Strategy: utils.RoundRobin})
+	require.NoError(t, err, "failed to create dynamic pool")
+	backends := addBackends(t, d, "http://127.0.0.1:8081", "http://127.0.0.1:8082", "http://127.0.0.1:8083")
+	backends[0].SetWeight(5)
+
+	require.NoError(t, d.SetOptions(Options{Strategy: utils.WeightedRoundRobin}))
+	assert.Equal(t, utils.WeightedRoundRobin, d.GetOptions().Strategy)
+	assert.Equal(t, backends, d.GetBackends())
+
+	// Weighted round-robin starts with the heaviest backend
+	assert.Equal(t, backends[0], d.GetNextValidPeer(nil))
+	assert.Equal(t, backends[0], d.GetNextValidPeer(nil))
+}
+
+// Test an invalid strategy is rejected and the current pool keeps running
+func TestDynamicPool_InvalidOptions(t *testing.T) {
+	d, err := NewDynamicPool(Options{Strategy: utils.RoundRobin})
+	require.NoError(t, err, "failed to create dynamic pool")
+	backends := addBackends(t, d, "http://127.0.0.1:8081", "http://127.0.0.1:8082")
+
+	assert.Error(t, d.SetOptions(Options{Strategy: utils.ConsistentHash, HashKey: "bogus"}))
+	assert.Equal(t, utils.RoundRobin, d.GetOptions().Strategy)
+	assert.Equal(t, backends, d.GetBackends())
+	assert.NotNil(t, d.GetNextValidPeer(nil))
+}
+
+// Test add, remove and drain go to the current pool
+func TestDynamicPool_AddRemoveDrain(t *testing.T) {
+	d, err := NewDynamicPool(Options{Strategy: utils.RoundRobin})
+	require.NoError(t, err, "failed to create dynamic pool")
+	backends := addBackends(t, d, "http://127.0.0.1:8081", "http://127.0.0.1:8082", "http://127.0.0.1:8083")
+
+	var mux sync.Mutex
+	var removed []backend.Backend
+	d.SetRemoveObserver(func(b backend.Backend) {
+		mux.Lock()
+		removed = append(removed, b)
+		mux.Unlock()
+	})
+
+	assert.True(t, d.RemoveBackend(backends[0].GetURL().String()))
+	assert.False(t, d.RemoveBackend(backends[0].GetURL().String()))
+	assert.Equal(t, 2, d.GetServerPoolSize())
+
+	require.True(t, d.DrainBackend(backends[1].GetURL().String(), time.Second))
+
+	// The drained backend is removed from the pool current at removal time
+	require.NoError(t, d.SetOptions(Options{Strategy: utils.LeastConnected}))
+	assert.Eventually(t, func() bool { return d.GetServerPoolSize() == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []backend.Backend{backends[2]}, d.GetBackends())
+
+	mux.Lock()
+	defer mux.Unlock()
+	assert.Equal(t, []backend.Backend{backends[0], backends[1]}, removed)
+}
+
+// Test selection keeps working while the strategy is switched
+func TestDynamicPool_ConcurrentSwitch(t *testing.T) {
+	d, err := NewDynamicPool(Options{Strategy: utils.RoundRobin})
+	require.NoError(t, err, "failed to create dynamic pool")
+	addBackends(t, d, "http://127.0.0.1:8081", "http://127.0.0.1:8082", "http://127.0.0.1:8083")
+
+	strategies := []utils.LBStrategy{utils.LeastConnected, utils.PowerOfTwoChoices, utils.RoundRobin}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				assert.NotNil(t, d.GetNextValidPeer(nil))
+			}
+		}()
+	}
+	for _, s := range strategies {
+		require.NoError(t, d.SetOptions(Options{Strategy: s}))
+	}
+	wg.Wait()
+
+	assert.Equal(t, 3, d.GetServerPoolSize())
+}
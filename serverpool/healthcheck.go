@@ -3,95 +3,334 @@ package serverpool
 import (
 	"context"
 	"load-balancer/backend"
-	"load-balancer/utils"
+	"math/rand/v2"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
 )
 
-// HealthCheck iterates over all backends in the server pool and updates their alive status.
-// It runs asynchronously for each backend with a timeout defined in the load balancer config.
-// The results are sent through a channel, and the backend status is updated accordingly.
-// If the context is canceled, the health check exits gracefully.
-func HealthCheck(ctx context.Context, s ServerPool, logger *zap.Logger) {
-	config, err := utils.GetLBConfig()
-	if err != nil {
-		logger.Error("failed to get config for health check", zap.Error(err))
-		return
+// HealthCheckOptions configures active health checking.
+type HealthCheckOptions struct {
+	Interval     time.Duration // time between checks of a backend (default 20s)
+	Timeout      time.Duration // time allowed for a single backend check (default 2s)
+	Jitter       float64       // fraction of the interval every delay is randomly spread by (default 0.1, negative disables)
+	BackoffAfter time.Duration // time a backend must be down before its checks back off (default 1m)
+	MaxInterval  time.Duration // longest time between checks of a dead backend (default 5m)
+}
+
+// withDefaults returns the options with defaults applied to unset fields.
+func (o HealthCheckOptions) withDefaults() HealthCheckOptions {
+	if o.Interval <= 0 {
+		o.Interval = 20 * time.Second
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 2 * time.Second
+	}
+	if o.Jitter == 0 || o.Jitter >= 1 {
+		o.Jitter = 0.1
+	}
+	if o.Jitter < 0 {
+		o.Jitter = 0
 	}
+	if o.BackoffAfter <= 0 {
+		o.BackoffAfter = time.Minute
+	}
+	if o.MaxInterval <= 0 {
+		o.MaxInterval = 5 * time.Minute
+	}
+	if o.MaxInterval < o.Interval {
+		o.MaxInterval = o.Interval
+	}
+	return o
+}
+
+// probeState tracks the active health check of a single backend.
+type probeState struct {
+	mux       sync.Mutex         // held during a check, so checks of a backend never overlap
+	cancel    context.CancelFunc // stops the schedule of the backend
+	downSince time.Time          // zero while the backend is alive
+	backoff   int                // doublings of the interval while the backend stays down
+}
 
-	// Channel for receiving health check results from goroutines
-	ch := make(chan struct {
-		b     backend.Backend
-		alive bool
-	}, len(s.GetBackends()))
+// HealthMonitor runs the active health checks of a server pool.
+// Every backend is checked on its own jittered schedule, one check at a time,
+// and backends that have been down for a long time are checked less and less often.
+type HealthMonitor struct {
+	sp     ServerPool
+	opts   HealthCheckOptions
+	logger *zap.Logger
+	mux    sync.Mutex // protects opts and probes
+	probes map[backend.Backend]*probeState
+	reset  chan struct{} // tells Run the interval changed
 
-	// Launch concurrent health checks for each backend
-	for _, b := range s.GetBackends() {
-		go func(b backend.Backend) {
-			// Use a context with timeout for the backend check
-			reqCtx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(config.BackendTimeout))
-			defer cancel()
+	observer func(HealthCheckResult) // called with every check result
+}
 
-			alive := backend.CheckBackendHealth(reqCtx, b)
+// HealthCheckResult is the outcome of a single health check of a backend.
+type HealthCheckResult struct {
+	Backend  backend.Backend
+	Alive    bool          // alive status after the check
+	Err      error         // probe error, nil if the probe succeeded
+	Duration time.Duration // time taken by the probe
+}
 
-			// Send the result back to the main loop
-			ch <- struct {
-				b     backend.Backend
-				alive bool
-			}{b, alive}
-		}(b)
+// NewHealthMonitor creates a health monitor for the backends of the server pool.
+func NewHealthMonitor(sp ServerPool, opts HealthCheckOptions, logger *zap.Logger) *HealthMonitor {
+	return &HealthMonitor{
+		sp:     sp,
+		opts:   opts.withDefaults(),
+		logger: logger,
+		probes: make(map[backend.Backend]*probeState),
+		reset:  make(chan struct{}, 1),
 	}
-	// Collect results from all backend checks
-	for i := 0; i < len(s.GetBackends()); i++ {
+}
+
+// SetOptions changes the options of the running monitor.
+// When the interval changes every schedule is restarted, so backends are not left waiting on the old interval.
+func (m *HealthMonitor) SetOptions(opts HealthCheckOptions) {
+	m.mux.Lock()
+	opts = opts.withDefaults()
+	changed := opts.Interval != m.opts.Interval
+	m.opts = opts
+
+	if changed {
+		for _, st := range m.probes {
+			if st.cancel != nil {
+				st.cancel()
+				st.cancel = nil
+			}
+		}
+	}
+	m.mux.Unlock()
+
+	if changed {
 		select {
+		case m.reset <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// SetObserver registers a function called with the result of every health check.
+// It must be set before Run or CheckAll.
+func (m *HealthMonitor) SetObserver(fn func(HealthCheckResult)) {
+	m.observer = fn
+}
+
+// options returns the current options.
+func (m *HealthMonitor) options() HealthCheckOptions {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return m.opts
+}
+
+// Run schedules the checks of every backend in the pool until the context is canceled.
+// Backends added to or removed from the pool are picked up every interval.
+// Use CheckAll for immediate checks that must not overlap the scheduled ones.
+func (m *HealthMonitor) Run(ctx context.Context) {
+	m.logger.Info("launching health check")
+
+	m.sync(ctx)
+
+	// Ticker to pick up pool membership changes periodically
+	interval := m.options().Interval
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			m.sync(ctx)
+		case <-m.reset:
+			// Restart the schedules stopped by SetOptions on the new interval
+			interval = m.options().Interval
+			t.Reset(interval)
+			m.sync(ctx)
 		case <-ctx.Done():
-			// Stop the health check gracefully if the context is canceled
-			logger.Info("gracefully shutting down health check")
+			// Schedules stop with the context
+			m.logger.Info("stopping health check")
 			return
-		case res := <-ch:
-			// Update backend alive status
-			res.b.SetAlive(res.alive)
+		}
+	}
+}
 
-			status := "up"
+// CheckAll runs an immediate check of every backend in the pool and waits for the results.
+// A backend whose scheduled check is in flight is checked again once it finished.
+func (m *HealthMonitor) CheckAll(ctx context.Context) {
+	var wg sync.WaitGroup
 
-			if !res.alive {
-				status = "down"
-			}
+	for _, b := range m.sp.GetBackends() {
+		st := m.probe(b)
 
-			// Log the backend status
-			logger.Debug(
-				"url status",
-				zap.String("url", res.b.GetURL().String()),
-				zap.String("status", status),
-			)
-		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.check(ctx, b, st)
+		}()
 	}
+
+	wg.Wait()
 }
 
-// LaunchHealthCheck repeatedly runs health checks at intervals defined in the config.
-// It uses a ticker to schedule checks and exits when the provided context is canceled.
-func LaunchHealthCheck(ctx context.Context, s ServerPool, logger *zap.Logger) {
-	config, err := utils.GetLBConfig()
-	if err != nil {
-		logger.Fatal("failed to load config", zap.Error(err))
+// probe returns the state of the backend, creating it if needed.
+func (m *HealthMonitor) probe(b backend.Backend) *probeState {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	st, ok := m.probes[b]
+	if !ok {
+		st = &probeState{}
+		m.probes[b] = st
 	}
+	return st
+}
 
-	// Ticker to trigger health checks periodically
-	t := time.NewTicker(time.Second * time.Duration(config.HealthCheckInterval))
-	defer t.Stop()
+// sync starts a schedule for every new backend of the pool and stops the schedules of removed ones.
+func (m *HealthMonitor) sync(ctx context.Context) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	current := make(map[backend.Backend]struct{})
+	for _, b := range m.sp.GetBackends() {
+		current[b] = struct{}{}
 
-	logger.Info("launching health check")
+		st, ok := m.probes[b]
+		if !ok {
+			st = &probeState{}
+			m.probes[b] = st
+		}
+		if st.cancel == nil {
+			bctx, cancel := context.WithCancel(ctx)
+			st.cancel = cancel
+			go m.schedule(bctx, b, st)
+		}
+	}
+
+	for b, st := range m.probes {
+		if _, ok := current[b]; ok {
+			continue
+		}
+		if st.cancel != nil {
+			st.cancel()
+		}
+		delete(m.probes, b)
+	}
+}
+
+// schedule checks the backend repeatedly until the context is canceled.
+// The first check is delayed by a random part of the interval to spread backends over time.
+func (m *HealthMonitor) schedule(ctx context.Context, b backend.Backend, st *probeState) {
+	timer := time.NewTimer(time.Duration(rand.Int64N(int64(m.options().Interval))))
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-t.C:
-			// Run health check asynchronously
-			go HealthCheck(ctx, s, logger)
 		case <-ctx.Done():
-			// Stop launching health checks if context is canceled
-			logger.Info("stopping health check")
 			return
+		case <-timer.C:
+		}
+
+		m.check(ctx, b, st)
+		timer.Reset(m.nextDelay(st))
+	}
+}
+
+// nextDelay returns the jittered time until the next check of the backend.
+// The interval doubles after every check of a backend down for longer than BackoffAfter, up to MaxInterval.
+func (m *HealthMonitor) nextDelay(st *probeState) time.Duration {
+	opts := m.options()
+
+	st.mux.Lock()
+	defer st.mux.Unlock()
+
+	d := opts.Interval
+	if st.downSince.IsZero() || time.Since(st.downSince) < opts.BackoffAfter {
+		st.backoff = 0
+	} else {
+		st.backoff++
+		for i := 0; i < st.backoff && d < opts.MaxInterval; i++ {
+			d *= 2
+		}
+		d = min(d, opts.MaxInterval)
+	}
+
+	// Spread the delay by up to ±Jitter
+	spread := opts.Jitter * (2*rand.Float64() - 1)
+	return d + time.Duration(float64(d)*spread)
+}
+
+// check probes the backend and reports the result to it. Checks of a backend never overlap.
+func (m *HealthMonitor) check(ctx context.Context, b backend.Backend, st *probeState) {
+	st.mux.Lock()
+	defer st.mux.Unlock()
+
+	// Use a context with timeout for the backend check
+	reqCtx, cancel := context.WithTimeout(ctx, m.options().Timeout)
+	start := time.Now()
+	err := backend.CheckBackendHealth(reqCtx, b)
+	duration := time.Since(start)
+	cancel()
+
+	if ctx.Err() != nil {
+		// Stopped while checking, the result is meaningless
+		return
+	}
+
+	// Apply the result, the alive status only changes once a threshold is reached
+	tr := b.ReportHealthCheck(err == nil)
+	if m.observer != nil {
+		m.observer(HealthCheckResult{Backend: b, Alive: tr.Alive, Err: err, Duration: duration})
+	}
+
+	status := "up"
+
+	if !tr.Alive {
+		status = "down"
+		if st.downSince.IsZero() {
+			st.downSince = time.Now()
 		}
+	} else {
+		st.downSince = time.Time{}
+	}
+
+	if tr.Changed {
+		m.logger.Info(
+			"backend status change",
+			zap.String("url", b.GetURL().String()),
+			zap.String("status", status),
+			zap.String("reason", tr.Reason),
+			zap.NamedError("probe_error", err),
+		)
+		return
+	}
+	if tr.Reason != "" {
+		m.logger.Warn(
+			"backend held down",
+			zap.String("url", b.GetURL().String()),
+			zap.String("reason", tr.Reason),
+		)
 	}
+
+	// Log the backend status
+	m.logger.Debug(
+		"url status",
+		zap.String("url", b.GetURL().String()),
+		zap.String("status", status),
+		zap.NamedError("probe_error", err),
+	)
+}
+
+// HealthCheck runs a single check of every backend in the server pool and updates their alive status.
+// A backend only changes its alive status once its healthy or unhealthy threshold is reached,
+// and every status change is logged with its reason.
+// It does not coordinate with a running HealthMonitor, use its CheckAll for that.
+func HealthCheck(ctx context.Context, s ServerPool, opts HealthCheckOptions, logger *zap.Logger) {
+	NewHealthMonitor(s, opts, logger).CheckAll(ctx)
+}
+
+// LaunchHealthCheck checks every backend on its own schedule at the interval defined in the options.
+// It exits when the provided context is canceled.
+func LaunchHealthCheck(ctx context.Context, s ServerPool, opts HealthCheckOptions, logger *zap.Logger) {
+	NewHealthMonitor(s, opts, logger).Run(ctx)
 }
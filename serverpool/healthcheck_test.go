@@ -0,0 +1,227 @@
+package serverpool
+
+import (
+	"context"
+	"load-balancer/utils"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// Test a health check round marks failing backends down without a config file on disk
+func TestHealthCheck_UpdatesAliveStatus(t *testing.T) {
+	sp, err := NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	upServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upServer.Close()
+	downServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer downServer.Close()
+
+	backends := addBackends(t, sp, upServer.URL, downServer.URL)
+	up, down := backends[0], backends[1]
+
+	HealthCheck(context.Background(), sp, HealthCheckOptions{}, zap.NewNop())
+
+	assert.True(t, up.IsAlive())
+	assert.False(t, down.IsAlive())
+}
+
+// Test a backend slower than the timeout is marked down
+func TestHealthCheck_Timeout(t *testing.T) {
+	sp, err := NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer s.Close()
+	slow := addBackends(t, sp, s.URL)[0]
+
+	HealthCheck(context.Background(), sp, HealthCheckOptions{Timeout: 20 * time.Millisecond}, zap.NewNop())
+
+	assert.False(t, slow.IsAlive())
+}
+
+// Test health checks run at the configured interval until the context is canceled
+func TestLaunchHealthCheck(t *testing.T) {
+	sp, err := NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	var healthy atomic.Bool
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer s.Close()
+	b := addBackends(t, sp, s.URL)[0]
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		LaunchHealthCheck(ctx, sp, HealthCheckOptions{Interval: 10 * time.Millisecond}, zap.NewNop())
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return !b.IsAlive() }, time.Second, 5*time.Millisecond)
+
+	healthy.Store(true)
+	assert.Eventually(t, b.IsAlive, time.Second, 5*time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("health check did not stop after cancel")
+	}
+}
+
+// Test checks of a backend never overlap, even with a slow backend and an immediate round
+func TestHealthMonitor_NoOverlap(t *testing.T) {
+	sp, err := NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	var inFlight, maxInFlight, checks atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		checks.Add(1)
+		time.Sleep(30 * time.Millisecond)
+	}))
+	defer s.Close()
+	addBackends(t, sp, s.URL)
+
+	m := NewHealthMonitor(sp, HealthCheckOptions{Interval: 5 * time.Millisecond, Timeout: time.Second}, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+
+	assert.Eventually(t, func() bool { return checks.Load() >= 2 }, time.Second, 5*time.Millisecond)
+	m.CheckAll(context.Background())
+	m.CheckAll(context.Background())
+
+	assert.Equal(t, int32(1), maxInFlight.Load())
+}
+
+// Test backends added after the monitor started get their own schedule
+func TestHealthMonitor_PicksUpNewBackends(t *testing.T) {
+	sp, err := NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go NewHealthMonitor(sp, HealthCheckOptions{Interval: 10 * time.Millisecond}, zap.NewNop()).Run(ctx)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer s.Close()
+	b := addBackends(t, sp, s.URL)[0]
+
+	assert.Eventually(t, func() bool { return !b.IsAlive() }, time.Second, 5*time.Millisecond)
+}
+
+// Test the delay is jittered around the interval and backs off for backends down for a long time
+func TestHealthMonitor_NextDelay(t *testing.T) {
+	sp, err := NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	m := NewHealthMonitor(sp, HealthCheckOptions{
+		Interval:     10 * time.Second,
+		Jitter:       0.1,
+		BackoffAfter: time.Minute,
+		MaxInterval:  60 * time.Second,
+	}, zap.NewNop())
+
+	st := &probeState{}
+	for i := 0; i < 20; i++ {
+		d := m.nextDelay(st)
+		assert.GreaterOrEqual(t, d, 9*time.Second)
+		assert.LessOrEqual(t, d, 11*time.Second)
+	}
+
+	// Down, but not for long enough to back off
+	st.downSince = time.Now().Add(-30 * time.Second)
+	d := m.nextDelay(st)
+	assert.LessOrEqual(t, d, 11*time.Second)
+
+	// Down for long: 20s, 40s, then capped at 60s
+	st.downSince = time.Now().Add(-2 * time.Minute)
+	for _, want := range []time.Duration{20, 40, 60, 60} {
+		d := m.nextDelay(st)
+		assert.GreaterOrEqual(t, d, want*time.Second*9/10)
+		assert.LessOrEqual(t, d, want*time.Second*11/10)
+	}
+
+	// Back up, the backoff resets
+	st.downSince = time.Time{}
+	d = m.nextDelay(st)
+	assert.LessOrEqual(t, d, 11*time.Second)
+	assert.Equal(t, 0, st.backoff)
+	// A negative jitter disables it
+	m.SetOptions(HealthCheckOptions{Interval: 10 * time.Second, Jitter: -1})
+	assert.Equal(t, 10*time.Second, m.nextDelay(st))
+}
+
+// Test a new interval is picked up by the running schedules
+func TestHealthMonitor_SetOptions(t *testing.T) {
+	sp, err := NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer s.Close()
+	b := addBackends(t, sp, s.URL)[0]
+
+	m := NewHealthMonitor(sp, HealthCheckOptions{Interval: time.Hour}, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+
+	time.Sleep(20 * time.Millisecond)
+	require.True(t, b.IsAlive(), "no check expected yet")
+
+	m.SetOptions(HealthCheckOptions{Interval: 10 * time.Millisecond})
+	assert.Eventually(t, func() bool { return !b.IsAlive() }, time.Second, 5*time.Millisecond)
+}
+
+// Test the observer receives every check result with its duration
+func TestHealthMonitor_Observer(t *testing.T) {
+	sp, err := NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer s.Close()
+	down := addBackends(t, sp, s.URL)[0]
+
+	var results []HealthCheckResult
+	m := NewHealthMonitor(sp, HealthCheckOptions{}, zap.NewNop())
+	m.SetObserver(func(res HealthCheckResult) { results = append(results, res) })
+	m.CheckAll(context.Background())
+
+	require.Len(t, results, 1)
+	assert.Equal(t, down, results[0].Backend)
+	assert.False(t, results[0].Alive)
+	assert.Error(t, results[0].Err)
+	assert.Positive(t, results[0].Duration)
+}
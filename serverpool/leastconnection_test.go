@@ -57,7 +57,7 @@ func TestLeastConnection_ActiveConnections(t *testing.T) {
 
 	time.Sleep(500 * time.Millisecond)
 
-	peer := sp.GetNextValidPeer()
+	peer := sp.GetNextValidPeer(nil)
 	assert.Equal(t, b2.GetURL().String(), peer.GetURL().String())
 
 	wg.Wait()
@@ -79,7 +79,7 @@ func TestLeastConnection_AllBackendsIdle(t *testing.T) {
 	}
 
 	for i := 0; i < 10; i++ {
-		peer := sp.GetNextValidPeer()
+		peer := sp.GetNextValidPeer(nil)
 		assert.Equal(t, backends[0], peer)
 	}
 }
@@ -101,7 +101,7 @@ func TestLeastConnection_SkipDeadBackend(t *testing.T) {
 
 	sp.AddBackend(b2)
 
-	peer := sp.GetNextValidPeer()
+	peer := sp.GetNextValidPeer(nil)
 	assert.Equal(t, b1, peer) // should skip b2
 }
 
@@ -140,7 +140,7 @@ func TestLeastConnection_AllBackendsBusy(t *testing.T) {
 
 	time.Sleep(50 * time.Millisecond)
 
-	peer := sp.GetNextValidPeer()
+	peer := sp.GetNextValidPeer(nil)
 	require.NotNil(t, peer)
 
 	wg.Wait()
@@ -167,7 +167,7 @@ func TestLeastConnection_ConcurrentAccess(t *testing.T) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			sp.GetNextValidPeer()
+			sp.GetNextValidPeer(nil)
 		}()
 	}
 
@@ -214,3 +214,21 @@ func TestLeastConnection_AddDuplicate(t *testing.T) {
 	backends := sp.GetBackends()
 	assert.Len(t, backends, 1)
 }
+
+// Test a backend in slow start is not flooded because it has no connections
+func TestLeastConnection_SlowStart(t *testing.T) {
+	sp, err := NewServerPool(utils.LeastConnected)
+	require.NoError(t, err, "failed to create server pool")
+
+	u, err := url.Parse("http://127.0.0.1:8081")
+	require.NoError(t, err, "failed to parse url")
+	warming := backend.NewBackendWithOptions(u, backend.Options{SlowStart: time.Hour})
+	warming.SetAlive(false)
+	warming.SetAlive(true)
+	sp.AddBackend(warming)
+	full := addBackends(t, sp, "http://127.0.0.1:8082")[0]
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, full, sp.GetNextValidPeer(nil))
+	}
+}
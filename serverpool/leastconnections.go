@@ -2,19 +2,17 @@ package serverpool
 
 import (
 	"load-balancer/backend"
-	"sync"
+	"net/http"
 )
 
 // lcServerPool implements the ServerPool interface with least connections strategy.
 type lcServerPool struct {
-	backends   []backend.Backend   // slice of servers
-	backendMap map[string]struct{} // track urls
-	mux        sync.RWMutex        // RWMutex in read-heavy scenarios (lb has many reads)
+	backendList
 }
 
 // GetNextValidPeer returns the next alive backend server using least connections.
 // Returns nil if there is no alive backend found.
-func (s *lcServerPool) GetNextValidPeer() backend.Backend {
+func (s *lcServerPool) GetNextValidPeer(r *http.Request) backend.Backend {
 	s.mux.RLock()
 	// Copy the backend slice to avoid holding the lock during selection.
 	copied := make([]backend.Backend, len(s.backends))
@@ -25,8 +23,8 @@ func (s *lcServerPool) GetNextValidPeer() backend.Backend {
 
 	// Find least connected peer
 	for _, b := range copied {
-		// Skip backends that are not alive
-		if !b.IsAlive() {
+		// Skip backends that are not alive or excluded
+		if !IsSelectable(r, b) {
 			continue
 		}
 		// Set the first alive backend
@@ -34,8 +32,8 @@ func (s *lcServerPool) GetNextValidPeer() backend.Backend {
 			lc = b
 			continue
 		}
-		// Update the least connected peer
-		if lc.GetActiveConnections() > b.GetActiveConnections() {
+		// Update the least connected peer, backends in slow start count as more loaded
+		if effectiveLoad(lc) > effectiveLoad(b) {
 			lc = b
 		}
 	}
@@ -43,17 +41,6 @@ func (s *lcServerPool) GetNextValidPeer() backend.Backend {
 	return lc
 }
 
-// GetBackends returns a copy of all backend servers in the least connections pool.
-func (s *lcServerPool) GetBackends() []backend.Backend {
-	s.mux.RLock()
-	defer s.mux.RUnlock()
-
-	copied := make([]backend.Backend, len(s.backends))
-	copy(copied, s.backends)
-
-	return copied
-}
-
 // AddBackend adds new backend server to the least connections pool.
 func (s *lcServerPool) AddBackend(b backend.Backend) {
 	s.mux.Lock()
@@ -65,10 +52,3 @@ func (s *lcServerPool) AddBackend(b backend.Backend) {
 	s.backends = append(s.backends, b)
 	s.backendMap[u] = struct{}{}
 }
-
-// GetServerPoolSize returns the current number of servers in the least connections pool.
-func (s *lcServerPool) GetServerPoolSize() int {
-	s.mux.RLock()
-	defer s.mux.RUnlock()
-	return len(s.backends)
-}
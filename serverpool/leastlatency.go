@@ -0,0 +1,81 @@
+package serverpool
+
+import (
+	"load-balancer/backend"
+	"math"
+	"net/http"
+)
+
+// llServerPool implements the ServerPool interface with least latency strategy.
+// Each backend is scored by its peak-EWMA response time multiplied by its load (active connections + 1),
+// which catches backends that are slow but hold few connections.
+type llServerPool struct {
+	backendList
+}
+
+// latencyCost returns the selection cost of a backend.
+// A backend without latency samples costs nothing while idle, so it is tried first, but is
+// penalized as soon as it has requests in flight so it is not flooded before the first response.
+func latencyCost(b backend.Backend) float64 {
+	connections := b.GetActiveConnections()
+	latency := b.GetLatency()
+
+	if latency == 0 {
+		if connections == 0 {
+			return 0
+		}
+		return math.MaxFloat64
+	}
+
+	return float64(latency) * float64(connections+1)
+}
+
+// GetNextValidPeer returns the alive backend server with the lowest latency cost.
+// Returns nil if there is no alive backend found.
+func (s *llServerPool) GetNextValidPeer(r *http.Request) backend.Backend {
+	s.mux.RLock()
+	// Copy the backend slice to avoid holding the lock during selection.
+	copied := make([]backend.Backend, len(s.backends))
+	copy(copied, s.backends)
+	s.mux.RUnlock()
+
+	var best, fallback backend.Backend
+	var bestCost float64
+
+	for _, b := range copied {
+		// Skip backends that are not alive or excluded
+		if !IsSelectable(r, b) {
+			continue
+		}
+
+		// Backends in slow start cost more, and unmeasured ones are only tried now and then
+		cost := latencyCost(b) / b.GetSlowStartFactor()
+		if cost == 0 && !admitSlowStart(b) {
+			if fallback == nil {
+				fallback = b
+			}
+			continue
+		}
+		if best == nil || cost < bestCost {
+			best = b
+			bestCost = cost
+		}
+	}
+
+	if best == nil {
+		return fallback
+	}
+	return best
+}
+
+// AddBackend adds new backend server to the least latency pool.
+func (s *llServerPool) AddBackend(b backend.Backend) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	u := b.GetURL().String()
+	if _, exists := s.backendMap[u]; exists {
+		return
+	}
+	s.backends = append(s.backends, b)
+	s.backendMap[u] = struct{}{}
+}
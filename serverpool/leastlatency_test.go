@@ -0,0 +1,201 @@
+package serverpool
+
+import (
+	"load-balancer/backend"
+	"load-balancer/utils"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test selecting the backend with the lower response time
+func TestLeastLatency_PrefersFastBackend(t *testing.T) {
+	sp, err := NewServerPool(utils.LeastLatency)
+	require.NoError(t, err, "failed to create server pool")
+
+	slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer slowServer.Close()
+	fastServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer fastServer.Close()
+
+	backends := addBackends(t, sp, slowServer.URL, fastServer.URL)
+	slow, fast := backends[0], backends[1]
+
+	for _, b := range []backend.Backend{slow, fast} {
+		b.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	assert.Greater(t, slow.GetLatency(), fast.GetLatency())
+
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, fast, sp.GetNextValidPeer(nil))
+	}
+}
+
+// Test a slow backend with few connections loses to a busier fast backend
+func TestLeastLatency_LatencyTimesLoad(t *testing.T) {
+	sp, err := NewServerPool(utils.LeastLatency)
+	require.NoError(t, err, "failed to create server pool")
+
+	slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slowServer.Close()
+	fastServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer fastServer.Close()
+
+	backends := addBackends(t, sp, slowServer.URL, fastServer.URL)
+	slow, fast := backends[0], backends[1]
+
+	// Warm up latency samples
+	for _, b := range []backend.Backend{slow, fast} {
+		b.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	// Keep one request in flight on the fast backend
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		fast.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	// ~20ms * 2 is still cheaper than ~200ms * 1
+	assert.Equal(t, fast, sp.GetNextValidPeer(nil))
+
+	wg.Wait()
+}
+
+// Test unmeasured backends are tried first but not flooded
+func TestLeastLatency_UnmeasuredBackend(t *testing.T) {
+	sp, err := NewServerPool(utils.LeastLatency)
+	require.NoError(t, err, "failed to create server pool")
+
+	measuredServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer measuredServer.Close()
+	freshServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer freshServer.Close()
+
+	backends := addBackends(t, sp, measuredServer.URL, freshServer.URL)
+	measured, fresh := backends[0], backends[1]
+	measured.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, fresh, sp.GetNextValidPeer(nil))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		fresh.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// fresh has no sample yet but a request in flight
+	assert.Equal(t, measured, sp.GetNextValidPeer(nil))
+
+	wg.Wait()
+}
+
+// Test skipping dead backend with the lowest latency
+func TestLeastLatency_SkipDeadBackend(t *testing.T) {
+	sp, err := NewServerPool(utils.LeastLatency)
+	require.NoError(t, err, "failed to create server pool")
+
+	assert.Nil(t, sp.GetNextValidPeer(nil))
+
+	u1, err := url.Parse("http://127.0.0.1:8081")
+	require.NoError(t, err, "failed to parse url 1")
+	b1 := backend.NewBackend(u1)
+	b1.SetAlive(false)
+	sp.AddBackend(b1)
+
+	assert.Nil(t, sp.GetNextValidPeer(nil))
+
+	u2, err := url.Parse("http://127.0.0.1:8082")
+	require.NoError(t, err, "failed to parse url 2")
+	b2 := backend.NewBackend(u2)
+	sp.AddBackend(b2)
+
+	assert.Equal(t, b2, sp.GetNextValidPeer(nil)) // should skip b1
+}
+
+// Test concurrent access
+func TestLeastLatency_ConcurrentAccess(t *testing.T) {
+	sp, err := NewServerPool(utils.LeastLatency)
+	require.NoError(t, err, "failed to create server pool")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			u, err := url.Parse("http://127.0.0.1:808" + strconv.Itoa(i))
+			require.NoError(t, err, "failed to parse url")
+			sp.AddBackend(backend.NewBackend(u))
+		}(i)
+	}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sp.GetNextValidPeer(nil)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 5, sp.GetServerPoolSize())
+}
+
+// Test attempting to add duplicate url
+func TestLeastLatency_AddDuplicate(t *testing.T) {
+	sp, err := NewServerPool(utils.LeastLatency)
+	require.NoError(t, err, "failed to create server pool")
+
+	u, err := url.Parse("http://127.0.0.1:8081")
+	require.NoError(t, err, "failed to parse url")
+	b := backend.NewBackend(u)
+
+	sp.AddBackend(b)
+	sp.AddBackend(b) // should be ignored
+
+	assert.Len(t, sp.GetBackends(), 1)
+}
+
+// Test an unmeasured backend in slow start is only tried now and then
+func TestLeastLatency_SlowStart(t *testing.T) {
+	sp, err := NewServerPool(utils.LeastLatency)
+	require.NoError(t, err, "failed to create server pool")
+
+	u, err := url.Parse("http://127.0.0.1:8081")
+	require.NoError(t, err, "failed to parse url")
+	warming := backend.NewBackendWithOptions(u, backend.Options{SlowStart: time.Hour})
+	warming.SetAlive(false)
+	warming.SetAlive(true)
+	sp.AddBackend(warming)
+	addBackends(t, sp, "http://127.0.0.1:8082")
+
+	picked := 0
+	for i := 0; i < 1000; i++ {
+		if sp.GetNextValidPeer(nil) == warming {
+			picked++
+		}
+	}
+
+	assert.Greater(t, picked, 0)
+	assert.Less(t, picked, 250)
+}
@@ -1,9 +1,12 @@
 package serverpool
 
 import (
+	"load-balancer/backend"
 	"load-balancer/utils"
+	"net/url"
 	"testing"
 
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
@@ -12,3 +15,18 @@ func TestMain(m *testing.M) {
 
 	m.Run()
 }
+
+// addBackends adds a backend for each url to the pool and returns them in order.
+func addBackends(t *testing.T, sp ServerPool, urls ...string) []backend.Backend {
+	t.Helper()
+
+	backends := make([]backend.Backend, 0, len(urls))
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		require.NoError(t, err, "failed to parse url")
+		b := backend.NewBackend(u)
+		sp.AddBackend(b)
+		backends = append(backends, b)
+	}
+	return backends
+}
@@ -0,0 +1,157 @@
+package serverpool
+
+import (
+	"context"
+	"load-balancer/backend"
+	"load-balancer/requestid"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// OutlierOptions configures passive health checking.
+type OutlierOptions struct {
+	ConsecutiveFailures int           // consecutive 5xx responses or errors before ejection (default 5)
+	BaseEjectionTime    time.Duration // first ejection period, doubled on every further ejection (default 30s)
+	MaxEjectionTime     time.Duration // longest ejection period (default 300s)
+	MaxEjectionPercent  int           // maximum share of the pool ejected at once (default 50)
+	Logger              *zap.Logger   // ejections are logged here (default no-op)
+}
+
+// outlierStats tracks the recent results of a single backend.
+type outlierStats struct {
+	failures  int       // consecutive failures
+	ejections int       // ejections in a row, drives the exponential ejection period
+	lastEnd   time.Time // end of the last ejection
+}
+
+// OutlierDetector ejects backends that keep failing live traffic.
+// Unlike active health checks it does not touch the alive flag: an ejected backend is skipped by every
+// strategy until its ejection period is over, then gets live traffic again.
+type OutlierDetector struct {
+	sp    ServerPool
+	opts  OutlierOptions
+	mux   sync.Mutex
+	stats map[string]*outlierStats // keyed by backend url
+}
+
+// NewOutlierDetector creates an outlier detector for the backends of the server pool.
+func NewOutlierDetector(sp ServerPool, opts OutlierOptions) *OutlierDetector {
+	if opts.ConsecutiveFailures <= 0 {
+		opts.ConsecutiveFailures = 5
+	}
+	if opts.BaseEjectionTime <= 0 {
+		opts.BaseEjectionTime = 30 * time.Second
+	}
+	if opts.MaxEjectionTime <= 0 {
+		opts.MaxEjectionTime = 300 * time.Second
+	}
+	if opts.MaxEjectionTime < opts.BaseEjectionTime {
+		opts.MaxEjectionTime = opts.BaseEjectionTime
+	}
+	if opts.MaxEjectionPercent <= 0 {
+		opts.MaxEjectionPercent = 50
+	}
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop()
+	}
+
+	return &OutlierDetector{
+		sp:    sp,
+		opts:  opts,
+		stats: make(map[string]*outlierStats),
+	}
+}
+
+// getStats returns the stats of a backend, creating them if needed. Caller must hold d.mux.
+func (d *OutlierDetector) getStats(b backend.Backend) *outlierStats {
+	u := b.GetURL().String()
+	st, ok := d.stats[u]
+	if !ok {
+		st = &outlierStats{}
+		d.stats[u] = st
+	}
+	return st
+}
+
+// ObserveStatus records an upstream response. 5xx statuses count as failures, anything else resets the count.
+// ctx is the context of the request, its request id is logged with an ejection.
+func (d *OutlierDetector) ObserveStatus(ctx context.Context, b backend.Backend, statusCode int) {
+	if statusCode >= 500 {
+		d.ObserveFailure(ctx, b)
+		return
+	}
+
+	d.mux.Lock()
+	d.getStats(b).failures = 0
+	d.mux.Unlock()
+}
+
+// ObserveFailure records a failed request and ejects the backend once it reaches the consecutive failure threshold.
+// ctx is the context of the request, its request id is logged with an ejection.
+func (d *OutlierDetector) ObserveFailure(ctx context.Context, b backend.Backend) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	st := d.getStats(b)
+	st.failures++
+	if st.failures < d.opts.ConsecutiveFailures || b.IsEjected() {
+		return
+	}
+
+	logger := requestid.Logger(ctx, d.opts.Logger)
+	if !d.canEject() {
+		logger.Warn(
+			"outlier ejection skipped, max ejection percent reached",
+			zap.String("url", b.GetURL().String()),
+			zap.Int("max_ejection_percent", d.opts.MaxEjectionPercent),
+		)
+		return
+	}
+
+	now := time.Now()
+	// Start over once the backend stayed healthy for a full max ejection period
+	if !st.lastEnd.IsZero() && now.Sub(st.lastEnd) > d.opts.MaxEjectionTime {
+		st.ejections = 0
+	}
+
+	period := d.opts.BaseEjectionTime << st.ejections
+	if period > d.opts.MaxEjectionTime || period <= 0 {
+		period = d.opts.MaxEjectionTime
+	}
+	if period < d.opts.MaxEjectionTime {
+		st.ejections++
+	}
+
+	st.failures = 0
+	st.lastEnd = now.Add(period)
+	b.SetEjected(st.lastEnd)
+
+	logger.Warn(
+		"backend ejected",
+		zap.String("url", b.GetURL().String()),
+		zap.Duration("period", period),
+	)
+}
+
+// Forget drops the stats of a backend removed from the pool.
+func (d *OutlierDetector) Forget(b backend.Backend) {
+	d.mux.Lock()
+	delete(d.stats, b.GetURL().String())
+	d.mux.Unlock()
+}
+
+// canEject reports whether one more backend may be ejected without exceeding the max ejection percent.
+func (d *OutlierDetector) canEject() bool {
+	backends := d.sp.GetBackends()
+
+	ejected := 0
+	for _, b := range backends {
+		if b.IsEjected() {
+			ejected++
+		}
+	}
+
+	return (ejected+1)*100 <= len(backends)*d.opts.MaxEjectionPercent
+}
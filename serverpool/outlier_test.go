@@ -0,0 +1,140 @@
+package serverpool
+
+import (
+	"context"
+	"load-balancer/requestid"
+	"load-balancer/utils"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// Test a backend is ejected after consecutive failures and skipped by the strategy
+func TestOutlier_EjectsAfterConsecutiveFailures(t *testing.T) {
+	sp, err := NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+	backends := addBackends(t, sp, "http://127.0.0.1:8081", "http://127.0.0.1:8082")
+	d := NewOutlierDetector(sp, OutlierOptions{ConsecutiveFailures: 3, BaseEjectionTime: time.Minute})
+
+	d.ObserveFailure(context.Background(), backends[0])
+	d.ObserveStatus(context.Background(), backends[0], http.StatusInternalServerError)
+	assert.False(t, backends[0].IsEjected())
+
+	d.ObserveStatus(context.Background(), backends[0], http.StatusBadGateway)
+	assert.True(t, backends[0].IsEjected())
+	assert.True(t, backends[0].IsAlive(), "ejection must not touch the alive flag")
+
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, backends[1], sp.GetNextValidPeer(nil))
+	}
+}
+
+// Test successful responses reset the failure count
+func TestOutlier_SuccessResetsFailures(t *testing.T) {
+	sp, err := NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+	backends := addBackends(t, sp, "http://127.0.0.1:8081", "http://127.0.0.1:8082")
+	d := NewOutlierDetector(sp, OutlierOptions{ConsecutiveFailures: 3})
+
+	for i := 0; i < 10; i++ {
+		d.ObserveFailure(context.Background(), backends[0])
+		d.ObserveFailure(context.Background(), backends[0])
+		d.ObserveStatus(context.Background(), backends[0], http.StatusOK)
+	}
+
+	assert.False(t, backends[0].IsEjected())
+}
+
+// Test the ejection period grows exponentially up to the maximum
+func TestOutlier_ExponentialEjection(t *testing.T) {
+	sp, err := NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+	backends := addBackends(t, sp, "http://127.0.0.1:8081", "http://127.0.0.1:8082")
+	d := NewOutlierDetector(sp, OutlierOptions{
+		ConsecutiveFailures: 1,
+		BaseEjectionTime:    10 * time.Millisecond,
+		MaxEjectionTime:     40 * time.Millisecond,
+	})
+
+	expected := []time.Duration{10, 20, 40, 40}
+	for _, want := range expected {
+		start := time.Now()
+		d.ObserveFailure(context.Background(), backends[0])
+		require.True(t, backends[0].IsEjected())
+
+		for backends[0].IsEjected() {
+			time.Sleep(time.Millisecond)
+		}
+		elapsed := time.Since(start)
+
+		assert.GreaterOrEqual(t, elapsed, want*time.Millisecond)
+		assert.Less(t, elapsed, want*time.Millisecond+30*time.Millisecond)
+	}
+}
+
+// Test the max ejection percent keeps part of the pool in rotation
+func TestOutlier_MaxEjectionPercent(t *testing.T) {
+	sp, err := NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+	backends := addBackends(t, sp, "http://127.0.0.1:8081", "http://127.0.0.1:8082", "http://127.0.0.1:8083", "http://127.0.0.1:8084")
+	d := NewOutlierDetector(sp, OutlierOptions{ConsecutiveFailures: 1, MaxEjectionPercent: 50})
+
+	for _, b := range backends {
+		d.ObserveFailure(context.Background(), b)
+	}
+
+	ejected := 0
+	for _, b := range backends {
+		if b.IsEjected() {
+			ejected++
+		}
+	}
+	assert.Equal(t, 2, ejected)
+	assert.NotNil(t, sp.GetNextValidPeer(nil))
+}
+
+// Test a single backend pool is never emptied with the default percent
+func TestOutlier_SingleBackend(t *testing.T) {
+	sp, err := NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+	backends := addBackends(t, sp, "http://127.0.0.1:8081")
+	d := NewOutlierDetector(sp, OutlierOptions{ConsecutiveFailures: 1})
+
+	d.ObserveFailure(context.Background(), backends[0])
+
+	assert.False(t, backends[0].IsEjected())
+	assert.Equal(t, backends[0], sp.GetNextValidPeer(nil))
+}
+
+// Test ejections are logged to the injected logger with the request id, and the stats of removed backends are dropped
+func TestOutlier_LoggerAndForget(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	sp, err := NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+	backends := addBackends(t, sp, "http://127.0.0.1:8081", "http://127.0.0.1:8082")
+	d := NewOutlierDetector(sp, OutlierOptions{ConsecutiveFailures: 2, Logger: zap.New(core)})
+
+	d.ObserveFailure(context.Background(), backends[0])
+	d.ObserveFailure(requestid.NewContext(context.Background(), "req-1"), backends[0])
+	require.True(t, backends[0].IsEjected())
+	ejections := logs.FilterMessage("backend ejected")
+	require.Equal(t, 1, ejections.Len())
+	assert.Equal(t, "req-1", ejections.All()[0].ContextMap()["request_id"])
+
+	d.ObserveFailure(context.Background(), backends[1])
+	d.Forget(backends[1])
+	assert.NotContains(t, d.stats, backends[1].GetURL().String())
+
+	// Without a logger ejections are not logged, and do not panic
+	sp, err = NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+	backends = addBackends(t, sp, "http://127.0.0.1:8081", "http://127.0.0.1:8082")
+	d = NewOutlierDetector(sp, OutlierOptions{ConsecutiveFailures: 1})
+	d.ObserveFailure(context.Background(), backends[0])
+	assert.True(t, backends[0].IsEjected())
+}
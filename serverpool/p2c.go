@@ -0,0 +1,78 @@
+package serverpool
+
+import (
+	"load-balancer/backend"
+	"math/rand/v2"
+	"net/http"
+)
+
+// p2cServerPool implements the ServerPool interface with power-of-two-choices strategy.
+// Instead of scanning every backend like lcServerPool, it samples two random backends and picks the one
+// with fewer active connections. Selection is O(1) and the randomness avoids herding when several
+// load balancers see the same connection counts.
+type p2cServerPool struct {
+	backendList
+}
+
+// GetNextValidPeer samples two distinct backends and returns the alive one with fewer active connections.
+// If both samples are dead it falls back to the first alive backend from a random offset.
+// Returns nil if there is no alive backend found.
+func (s *p2cServerPool) GetNextValidPeer(r *http.Request) backend.Backend {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	n := len(s.backends)
+	switch n {
+	case 0:
+		return nil
+	case 1:
+		if IsSelectable(r, s.backends[0]) {
+			return s.backends[0]
+		}
+		return nil
+	}
+
+	// Pick two distinct indexes
+	i := rand.IntN(n)
+	j := rand.IntN(n - 1)
+	if j >= i {
+		j++
+	}
+
+	a, b := s.backends[i], s.backends[j]
+	aliveA, aliveB := IsSelectable(r, a), IsSelectable(r, b)
+
+	switch {
+	case aliveA && aliveB:
+		if effectiveLoad(b) < effectiveLoad(a) {
+			return b
+		}
+		return a
+	case aliveA:
+		return a
+	case aliveB:
+		return b
+	}
+
+	// Both samples are dead, scan for any alive backend
+	for k := 1; k < n; k++ {
+		peer := s.backends[(i+k)%n]
+		if IsSelectable(r, peer) {
+			return peer
+		}
+	}
+
+	return nil
+}
+
+// AddBackend adds new backend server to the power-of-two-choices pool.
+func (s *p2cServerPool) AddBackend(b backend.Backend) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	u := b.GetURL().String()
+	if _, exists := s.backendMap[u]; exists {
+		return
+	}
+	s.backends = append(s.backends, b)
+	s.backendMap[u] = struct{}{}
+}
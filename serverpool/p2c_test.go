@@ -0,0 +1,213 @@
+package serverpool
+
+import (
+	"load-balancer/backend"
+	"load-balancer/utils"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test selecting idle backend (with two backends both are always sampled)
+func TestP2C_ActiveConnections(t *testing.T) {
+	sp, err := NewServerPool(utils.PowerOfTwoChoices)
+	require.NoError(t, err, "failed to create server pool")
+
+	// Simulate backend with long-running request
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	})
+	s1 := httptest.NewServer(slow)
+	defer s1.Close()
+
+	u1, err := url.Parse(s1.URL)
+	require.NoError(t, err, "failed to parse url 1")
+	b1 := backend.NewBackend(u1)
+	sp.AddBackend(b1)
+
+	u2, err := url.Parse("http://127.0.0.1:8082")
+	require.NoError(t, err, "failed to parse url 2")
+	b2 := backend.NewBackend(u2)
+	sp.AddBackend(b2)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		b1.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, b2, sp.GetNextValidPeer(nil))
+	}
+
+	wg.Wait()
+}
+
+// Test idle backends are all used
+func TestP2C_SpreadsLoad(t *testing.T) {
+	sp, err := NewServerPool(utils.PowerOfTwoChoices)
+	require.NoError(t, err, "failed to create server pool")
+
+	for i := 0; i < 4; i++ {
+		u, err := url.Parse("http://127.0.0.1:808" + strconv.Itoa(i+1))
+		require.NoError(t, err, "failed to parse url")
+		sp.AddBackend(backend.NewBackend(u))
+	}
+
+	seen := map[backend.Backend]int{}
+	for i := 0; i < 400; i++ {
+		seen[sp.GetNextValidPeer(nil)]++
+	}
+
+	assert.Len(t, seen, 4)
+}
+
+// Test dead backends are never selected
+func TestP2C_SkipDeadBackend(t *testing.T) {
+	sp, err := NewServerPool(utils.PowerOfTwoChoices)
+	require.NoError(t, err, "failed to create server pool")
+
+	backends := []backend.Backend{}
+	for i := 0; i < 5; i++ {
+		u, err := url.Parse("http://127.0.0.1:808" + strconv.Itoa(i+1))
+		require.NoError(t, err, "failed to parse url")
+		b := backend.NewBackend(u)
+		sp.AddBackend(b)
+		backends = append(backends, b)
+	}
+
+	// Leave a single alive backend so both samples are often dead
+	for _, b := range backends[:4] {
+		b.SetAlive(false)
+	}
+
+	for i := 0; i < 50; i++ {
+		assert.Equal(t, backends[4], sp.GetNextValidPeer(nil))
+	}
+}
+
+// Test all backends dead, single backend and empty pool
+func TestP2C_NoAlivePeer(t *testing.T) {
+	sp, err := NewServerPool(utils.PowerOfTwoChoices)
+	require.NoError(t, err, "failed to create server pool")
+
+	assert.Nil(t, sp.GetNextValidPeer(nil))
+
+	u1, err := url.Parse("http://127.0.0.1:8081")
+	require.NoError(t, err, "failed to parse url 1")
+	b1 := backend.NewBackend(u1)
+	sp.AddBackend(b1)
+
+	assert.Equal(t, b1, sp.GetNextValidPeer(nil))
+
+	b1.SetAlive(false)
+	assert.Nil(t, sp.GetNextValidPeer(nil))
+
+	u2, err := url.Parse("http://127.0.0.1:8082")
+	require.NoError(t, err, "failed to parse url 2")
+	b2 := backend.NewBackend(u2)
+	b2.SetAlive(false)
+	sp.AddBackend(b2)
+
+	assert.Nil(t, sp.GetNextValidPeer(nil))
+}
+
+// Test concurrent access
+func TestP2C_ConcurrentAccess(t *testing.T) {
+	sp, err := NewServerPool(utils.PowerOfTwoChoices)
+	require.NoError(t, err, "failed to create server pool")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			u, err := url.Parse("http://127.0.0.1:808" + strconv.Itoa(i))
+			require.NoError(t, err, "failed to parse url")
+			sp.AddBackend(backend.NewBackend(u))
+		}(i)
+	}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sp.GetNextValidPeer(nil)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 5, sp.GetServerPoolSize())
+}
+
+// Test attempting to add duplicate url
+func TestP2C_AddDuplicate(t *testing.T) {
+	sp, err := NewServerPool(utils.PowerOfTwoChoices)
+	require.NoError(t, err, "failed to create server pool")
+
+	u, err := url.Parse("http://127.0.0.1:8081")
+	require.NoError(t, err, "failed to parse url")
+	b := backend.NewBackend(u)
+
+	sp.AddBackend(b)
+	sp.AddBackend(b) // should be ignored
+
+	assert.Len(t, sp.GetBackends(), 1)
+}
+
+func benchmarkPeerSelection(b *testing.B, strategy utils.LBStrategy, n int) {
+	sp, err := NewServerPool(strategy)
+	require.NoError(b, err, "failed to create server pool")
+
+	for i := 0; i < n; i++ {
+		u, err := url.Parse("http://10.0." + strconv.Itoa(i/256) + "." + strconv.Itoa(i%256) + ":8080")
+		require.NoError(b, err, "failed to parse url")
+		sp.AddBackend(backend.NewBackend(u))
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			sp.GetNextValidPeer(nil)
+		}
+	})
+}
+
+func BenchmarkP2C_10(b *testing.B)   { benchmarkPeerSelection(b, utils.PowerOfTwoChoices, 10) }
+func BenchmarkP2C_100(b *testing.B)  { benchmarkPeerSelection(b, utils.PowerOfTwoChoices, 100) }
+func BenchmarkP2C_1000(b *testing.B) { benchmarkPeerSelection(b, utils.PowerOfTwoChoices, 1000) }
+
+func BenchmarkLeastConnection_10(b *testing.B)  { benchmarkPeerSelection(b, utils.LeastConnected, 10) }
+func BenchmarkLeastConnection_100(b *testing.B) { benchmarkPeerSelection(b, utils.LeastConnected, 100) }
+func BenchmarkLeastConnection_1000(b *testing.B) {
+	benchmarkPeerSelection(b, utils.LeastConnected, 1000)
+}
+
+// Test a backend in slow start loses a tie on connections
+func TestP2C_SlowStart(t *testing.T) {
+	sp, err := NewServerPool(utils.PowerOfTwoChoices)
+	require.NoError(t, err, "failed to create server pool")
+
+	u, err := url.Parse("http://127.0.0.1:8081")
+	require.NoError(t, err, "failed to parse url")
+	warming := backend.NewBackendWithOptions(u, backend.Options{SlowStart: time.Hour})
+	warming.SetAlive(false)
+	warming.SetAlive(true)
+	sp.AddBackend(warming)
+	full := addBackends(t, sp, "http://127.0.0.1:8082")[0]
+
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, full, sp.GetNextValidPeer(nil))
+	}
+}
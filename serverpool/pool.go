@@ -4,36 +4,79 @@ import (
 	"fmt"
 	"load-balancer/backend"
 	"load-balancer/utils"
+	"net/http"
+	"time"
 
 	"go.uber.org/zap"
 )
 
 // ServerPool defines methods for managing backend servers and selecting a backend according to a load balancing strategy.
+// GetNextValidPeer receives the incoming request so strategies can select on headers, client address, path or cookies.
+// Strategies that do not look at the request must accept a nil request.
 type ServerPool interface {
 	GetBackends() []backend.Backend
-	GetNextValidPeer() backend.Backend
+	GetNextValidPeer(*http.Request) backend.Backend
 	AddBackend(backend.Backend)
+	RemoveBackend(url string) bool                       // remove at once, false if the url is not in the pool
+	RemoveBackendIf(b backend.Backend) bool              // remove b at once if it is still the backend of its url
+	DrainBackend(url string, timeout time.Duration) bool // remove once idle or after timeout, false if the url is not in the pool
 	GetServerPoolSize() int
 }
 
+// Options configures a server pool.
+// Fields that do not apply to the selected strategy are ignored.
+type Options struct {
+	Strategy     utils.LBStrategy
+	HashKey      string      // consistent-hash request key: "ip", "path", "header:<name>" or "cookie:<name>"
+	VirtualNodes int         // consistent-hash ring points per backend
+	Logger       *zap.Logger // drains are logged here (default no-op)
+}
+
 // NewServerPool creates a new server pool using provided load balancing strategy (default round-robin).
 // Returns an error if the strategy is unsupported.
 func NewServerPool(strategy utils.LBStrategy) (ServerPool, error) {
-	switch strategy {
+	return NewServerPoolWithOptions(Options{Strategy: strategy})
+}
+
+// NewServerPoolWithOptions creates a new server pool from the provided options.
+// Returns an error if the strategy is unsupported.
+func NewServerPoolWithOptions(opts Options) (ServerPool, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	switch opts.Strategy {
 	case utils.RoundRobin:
-		return &roundRobinServerPool{
-			backends:   make([]backend.Backend, 0),
-			backendMap: make(map[string]struct{}),
-			current:    0,
-		}, nil
+		s := &roundRobinServerPool{current: 0}
+		s.init(logger, s.removed)
+		return s, nil
 	case utils.LeastConnected:
-		return &lcServerPool{
-			backends:   make([]backend.Backend, 0),
-			backendMap: make(map[string]struct{}),
-		}, nil
+		s := &lcServerPool{}
+		s.init(logger, nil)
+		return s, nil
+	case utils.WeightedRoundRobin:
+		s := &wrrServerPool{currentWeights: make([]int, 0)}
+		s.init(logger, s.removed)
+		return s, nil
+	case utils.PowerOfTwoChoices:
+		s := &p2cServerPool{}
+		s.init(logger, nil)
+		return s, nil
+	case utils.LeastLatency:
+		s := &llServerPool{}
+		s.init(logger, nil)
+		return s, nil
+	case utils.ConsistentHash:
+		pool, err := newConsistentHashServerPool(opts.HashKey, opts.VirtualNodes)
+		if err != nil {
+			return nil, err
+		}
+		pool.logger = logger
+		return pool, nil
 
 	default:
-		utils.Logger.Error("invalid server pool strategy", zap.Int("strategy", int(strategy)))
-		return nil, fmt.Errorf("invalid strategy: %d", strategy)
+		logger.Error("invalid server pool strategy", zap.Int("strategy", int(opts.Strategy)))
+		return nil, fmt.Errorf("invalid strategy: %d", opts.Strategy)
 	}
 }
@@ -30,6 +30,42 @@ func TestLeastConnectionPoolCreation(t *testing.T) {
 	assert.Empty(t, sp.GetBackends(), "new pool should have no backends")
 }
 
+// Test creating Weighted Round Robin pool
+func TestWeightedRoundRobinPoolCreation(t *testing.T) {
+	sp, err := NewServerPool(utils.WeightedRoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	assert.Equal(t, 0, sp.GetServerPoolSize(), "new pool should be empty")
+	assert.Empty(t, sp.GetBackends(), "new pool should have no backends")
+}
+
+// Test creating Consistent Hash pool
+func TestConsistentHashPoolCreation(t *testing.T) {
+	sp, err := NewServerPool(utils.ConsistentHash)
+	require.NoError(t, err, "failed to create server pool")
+
+	assert.Equal(t, 0, sp.GetServerPoolSize(), "new pool should be empty")
+	assert.Empty(t, sp.GetBackends(), "new pool should have no backends")
+}
+
+// Test creating Power of Two Choices pool
+func TestP2CPoolCreation(t *testing.T) {
+	sp, err := NewServerPool(utils.PowerOfTwoChoices)
+	require.NoError(t, err, "failed to create server pool")
+
+	assert.Equal(t, 0, sp.GetServerPoolSize(), "new pool should be empty")
+	assert.Empty(t, sp.GetBackends(), "new pool should have no backends")
+}
+
+// Test creating Least Latency pool
+func TestLeastLatencyPoolCreation(t *testing.T) {
+	sp, err := NewServerPool(utils.LeastLatency)
+	require.NoError(t, err, "failed to create server pool")
+
+	assert.Equal(t, 0, sp.GetServerPoolSize(), "new pool should be empty")
+	assert.Empty(t, sp.GetBackends(), "new pool should have no backends")
+}
+
 // Test invalid load balancing strategy
 func TestNewServerPool_InvalidStrategy(t *testing.T) {
 	invalid := utils.LBStrategy(999)
@@ -87,7 +123,7 @@ func TestGetNextValidPeer_EmptyPool(t *testing.T) {
 	sp, err := NewServerPool(utils.RoundRobin)
 	require.NoError(t, err, "failed to create server pool")
 
-	assert.Nil(t, sp.GetNextValidPeer())
+	assert.Nil(t, sp.GetNextValidPeer(nil))
 }
 
 // Test GetNextValidPeer() skips dead backend
@@ -107,7 +143,7 @@ func TestGetNextValidPeer_SkipsDead(t *testing.T) {
 	b2.SetAlive(true)
 	sp.AddBackend(b2)
 
-	peer := sp.GetNextValidPeer()
+	peer := sp.GetNextValidPeer(nil)
 
 	assert.Equal(t, b2.GetURL().String(), peer.GetURL().String())
 }
@@ -123,7 +159,7 @@ func TestGetNextValidPeer_AllDead(t *testing.T) {
 	b1.SetAlive(false)
 	sp.AddBackend(b1)
 
-	assert.Nil(t, sp.GetNextValidPeer())
+	assert.Nil(t, sp.GetNextValidPeer(nil))
 }
 
 // Test concurrent AddBackend() and GetNextValidPeer()
@@ -147,7 +183,7 @@ func TestConcurrentAccess(t *testing.T) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			peer := sp.GetNextValidPeer()
+			peer := sp.GetNextValidPeer(nil)
 			if peer != nil {
 				assert.True(t, peer.IsAlive())
 			}
@@ -187,3 +223,55 @@ func TestGetBackendsImmutability(t *testing.T) {
 	// Length and order should match
 	assert.Equal(t, b1, backendsAfter[0])
 }
+
+// Test every strategy stops selecting a removed backend and allows adding it again
+func TestRemoveBackend(t *testing.T) {
+	strategies := []utils.LBStrategy{
+		utils.RoundRobin,
+		utils.LeastConnected,
+		utils.WeightedRoundRobin,
+		utils.ConsistentHash,
+		utils.PowerOfTwoChoices,
+		utils.LeastLatency,
+	}
+
+	for _, strategy := range strategies {
+		sp, err := NewServerPool(strategy)
+		require.NoError(t, err, "failed to create server pool")
+
+		backends := []backend.Backend{}
+		for i := 0; i < 3; i++ {
+			u, err := url.Parse("http://127.0.0.1:808" + strconv.Itoa(i+1))
+			require.NoError(t, err, "failed to parse url")
+			b := backend.NewBackend(u)
+			sp.AddBackend(b)
+			backends = append(backends, b)
+		}
+
+		assert.True(t, sp.RemoveBackend("http://127.0.0.1:8082"), "strategy %d", strategy)
+		assert.False(t, sp.RemoveBackend("http://127.0.0.1:8082"), "strategy %d", strategy)
+		assert.False(t, sp.RemoveBackend("http://127.0.0.1:9999"), "strategy %d", strategy)
+		assert.Equal(t, 2, sp.GetServerPoolSize(), "strategy %d", strategy)
+		assert.Equal(t, []backend.Backend{backends[0], backends[2]}, sp.GetBackends(), "strategy %d", strategy)
+
+		for i := 0; i < 50; i++ {
+			peer := sp.GetNextValidPeer(requestFrom("10.0.0." + strconv.Itoa(i)))
+			require.NotNil(t, peer, "strategy %d", strategy)
+			assert.NotEqual(t, backends[1], peer, "strategy %d", strategy)
+		}
+
+		sp.AddBackend(backends[1])
+		assert.Equal(t, 3, sp.GetServerPoolSize(), "strategy %d", strategy)
+
+		// RemoveBackendIf only removes the very backend registered under the url
+		assert.False(t, sp.RemoveBackendIf(backend.NewBackend(backends[1].GetURL())), "strategy %d", strategy)
+		assert.True(t, sp.RemoveBackendIf(backends[1]), "strategy %d", strategy)
+		sp.AddBackend(backends[1])
+
+		// Emptying the pool leaves nothing to select
+		for _, b := range backends {
+			assert.True(t, sp.RemoveBackend(b.GetURL().String()), "strategy %d", strategy)
+		}
+		assert.Nil(t, sp.GetNextValidPeer(requestFrom("10.0.0.1")), "strategy %d", strategy)
+	}
+}
@@ -2,20 +2,18 @@ package serverpool
 
 import (
 	"load-balancer/backend"
-	"sync"
+	"net/http"
 )
 
 // roundRobinServerPool implements the ServerPool interface with round-robin strategy.
 type roundRobinServerPool struct {
-	backends   []backend.Backend   // slice of servers
-	backendMap map[string]struct{} // track urls
-	mux        sync.RWMutex        // RWMutex in read-heavy scenarios (lb has many reads)
-	current    int                 // index of the last selected backend
+	backendList
+	current int // index of the last selected backend
 }
 
 // GetNextValidPeer returns the next alive backend server using round-robin.
 // Returns nil if there is no alive backend found.
-func (s *roundRobinServerPool) GetNextValidPeer() backend.Backend {
+func (s *roundRobinServerPool) GetNextValidPeer(r *http.Request) backend.Backend {
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
@@ -24,26 +22,23 @@ func (s *roundRobinServerPool) GetNextValidPeer() backend.Backend {
 		return nil
 	}
 
+	// Backends in slow start pass their turn now and then, falling back to the first selectable one
+	var fallback backend.Backend
 	for i := 0; i < n; i++ {
 		s.current = (s.current + 1) % n
 		peer := s.backends[s.current]
-		if peer.IsAlive() {
+		if !IsSelectable(r, peer) {
+			continue
+		}
+		if admitSlowStart(peer) {
 			return peer
 		}
+		if fallback == nil {
+			fallback = peer
+		}
 	}
 
-	return nil
-}
-
-// GetBackends returns a copy of all backend servers in the round-robin pool.
-func (s *roundRobinServerPool) GetBackends() []backend.Backend {
-	s.mux.RLock()
-	defer s.mux.RUnlock()
-
-	copied := make([]backend.Backend, len(s.backends))
-	copy(copied, s.backends)
-
-	return copied
+	return fallback
 }
 
 // AddBackend adds new backend server to the round-robin pool.
@@ -58,9 +53,10 @@ func (s *roundRobinServerPool) AddBackend(b backend.Backend) {
 	s.backendMap[u] = struct{}{}
 }
 
-// GetServerPoolSize returns the current number of servers in the round-robin pool.
-func (s *roundRobinServerPool) GetServerPoolSize() int {
-	s.mux.RLock()
-	defer s.mux.RUnlock()
-	return len(s.backends)
+// removed keeps the position when the backend at index i is removed, so the next backend in line is not skipped.
+// The caller holds the lock.
+func (s *roundRobinServerPool) removed(i int) {
+	if i <= s.current {
+		s.current--
+	}
 }
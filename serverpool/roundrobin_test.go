@@ -3,10 +3,13 @@ package serverpool
 import (
 	"load-balancer/backend"
 	"load-balancer/utils"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strconv"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -28,7 +31,7 @@ func TestRoundRobin_Rotation(t *testing.T) {
 	}
 
 	for i := 0; i < 10; i++ {
-		peer := sp.GetNextValidPeer()
+		peer := sp.GetNextValidPeer(nil)
 		assert.Equal(t, backends[(i+1)%len(backends)], peer)
 	}
 }
@@ -38,7 +41,7 @@ func TestRoundRobin_EmptyPool(t *testing.T) {
 	sp, err := NewServerPool(utils.RoundRobin)
 	require.NoError(t, err, "failed to create server pool")
 
-	assert.Nil(t, sp.GetNextValidPeer())
+	assert.Nil(t, sp.GetNextValidPeer(nil))
 }
 
 // Test pool with single backend
@@ -51,7 +54,7 @@ func TestRoundRobin_SinglePool(t *testing.T) {
 	b := backend.NewBackend(u1)
 	sp.AddBackend(b)
 
-	peer := sp.GetNextValidPeer()
+	peer := sp.GetNextValidPeer(nil)
 
 	assert.Equal(t, b, peer)
 }
@@ -75,7 +78,7 @@ func TestRoundRobin_SkipDeadBackend(t *testing.T) {
 
 	sp.AddBackend(b2)
 
-	peer := sp.GetNextValidPeer()
+	peer := sp.GetNextValidPeer(nil)
 	assert.Equal(t, b1, peer) // should skip b2
 }
 
@@ -95,7 +98,7 @@ func TestRoundRobin_ConcurrentAccess(t *testing.T) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			sp.GetNextValidPeer()
+			sp.GetNextValidPeer(nil)
 		}()
 	}
 	wg.Wait()
@@ -145,7 +148,7 @@ func TestRoundRobin_MultipleDeadBackends(t *testing.T) {
 	backends[4].SetAlive(false)
 
 	for i := 0; i < 15; i++ {
-		peer := sp.GetNextValidPeer()
+		peer := sp.GetNextValidPeer(nil)
 		assert.True(t, peer.IsAlive())
 		assert.NotEqual(t, backends[1], peer)
 		assert.NotEqual(t, backends[3], peer)
@@ -172,7 +175,7 @@ func TestRoundRobin_ReviveDeadBackends(t *testing.T) {
 	backends[4].SetAlive(false)
 
 	for i := 0; i < 15; i++ {
-		peer := sp.GetNextValidPeer()
+		peer := sp.GetNextValidPeer(nil)
 		assert.True(t, peer.IsAlive())
 		assert.NotEqual(t, backends[1], peer)
 		assert.NotEqual(t, backends[3], peer)
@@ -182,7 +185,7 @@ func TestRoundRobin_ReviveDeadBackends(t *testing.T) {
 	backends[3].SetAlive(true)
 
 	for i := 0; i < 15; i++ {
-		peer := sp.GetNextValidPeer()
+		peer := sp.GetNextValidPeer(nil)
 		assert.True(t, peer.IsAlive())
 		assert.NotEqual(t, backends[1], peer)
 		assert.NotEqual(t, backends[4], peer)
@@ -204,3 +207,78 @@ func TestRoundRobin_AddDuplicate(t *testing.T) {
 	backends := sp.GetBackends()
 	assert.Len(t, backends, 1)
 }
+
+// Test backend behind an open circuit is skipped
+func TestRoundRobin_SkipOpenCircuit(t *testing.T) {
+	sp, err := NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	u1, err := url.Parse("http://127.0.0.1:8081")
+	require.NoError(t, err, "failed to parse url 1")
+	b1 := backend.NewBackend(u1)
+	b1.SetCircuitBreaker(backend.BreakerOptions{MinimumRequests: 1, OpenTimeout: time.Minute})
+	sp.AddBackend(b1)
+
+	u2, err := url.Parse("http://127.0.0.1:8082")
+	require.NoError(t, err, "failed to parse url 2")
+	b2 := backend.NewBackend(u2)
+	sp.AddBackend(b2)
+
+	// Trip the breaker with a refused connection
+	b1.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	require.True(t, b1.IsCircuitOpen())
+
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, b2, sp.GetNextValidPeer(nil))
+	}
+}
+
+// Test a backend in slow start passes most of its turns
+func TestRoundRobin_SlowStart(t *testing.T) {
+	sp, err := NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	u, err := url.Parse("http://127.0.0.1:8081")
+	require.NoError(t, err, "failed to parse url")
+	warming := backend.NewBackendWithOptions(u, backend.Options{SlowStart: time.Hour})
+	warming.SetAlive(false)
+	warming.SetAlive(true)
+	sp.AddBackend(warming)
+	addBackends(t, sp, "http://127.0.0.1:8082")
+
+	picked := 0
+	for i := 0; i < 1000; i++ {
+		if sp.GetNextValidPeer(nil) == warming {
+			picked++
+		}
+	}
+
+	// About half of the turns are its own, and it takes a tenth of them
+	assert.Greater(t, picked, 0)
+	assert.Less(t, picked, 150)
+}
+
+// Test removing a backend keeps the rotation order of the others
+func TestRoundRobin_RemoveKeepsOrder(t *testing.T) {
+	sp, err := NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	backends := []backend.Backend{}
+	for i := 0; i < 4; i++ {
+		u, err := url.Parse("http://127.0.0.1:808" + strconv.Itoa(i+1))
+		require.NoError(t, err, "failed to parse url")
+		b := backend.NewBackend(u)
+		sp.AddBackend(b)
+		backends = append(backends, b)
+	}
+
+	// Rotation starts at the second backend
+	assert.Equal(t, backends[1], sp.GetNextValidPeer(nil))
+	assert.Equal(t, backends[2], sp.GetNextValidPeer(nil))
+
+	// Removing the last selected backend, the next in line is still next
+	sp.RemoveBackend("http://127.0.0.1:8083")
+	assert.Equal(t, backends[3], sp.GetNextValidPeer(nil))
+	assert.Equal(t, backends[0], sp.GetNextValidPeer(nil))
+	assert.Equal(t, backends[1], sp.GetNextValidPeer(nil))
+}
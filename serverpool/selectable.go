@@ -0,0 +1,59 @@
+package serverpool
+
+import (
+	"context"
+	"load-balancer/backend"
+	"math/rand/v2"
+	"net/http"
+)
+
+// contextKey is used for type safe context values.
+type contextKey string
+
+const excludedPeersKey contextKey = "excluded_peers"
+
+// WithExcludedPeers returns a context telling the server pool not to select the backends whose URL is in excluded.
+// The set is read on every selection, so a caller may keep adding to it between attempts of the same request.
+func WithExcludedPeers(ctx context.Context, excluded map[string]struct{}) context.Context {
+	return context.WithValue(ctx, excludedPeersKey, excluded)
+}
+
+// IsSelectable reports whether a backend may receive the request.
+// The backend must be alive, not draining, not ejected by outlier detection, not behind an open circuit
+// and not excluded by the request context.
+func IsSelectable(r *http.Request, b backend.Backend) bool {
+	if !b.IsAlive() || b.IsDraining() || b.IsEjected() || b.IsCircuitOpen() {
+		return false
+	}
+
+	if r != nil {
+		if excluded, ok := r.Context().Value(excludedPeersKey).(map[string]struct{}); ok {
+			if _, skip := excluded[b.GetURL().String()]; skip {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// weightScale is the resolution of effective weights, so slow start can ramp a weight of 1.
+const weightScale = 100
+
+// effectiveWeight returns the backend weight in weightScale units, scaled down while the backend is in slow start.
+func effectiveWeight(b backend.Backend) int {
+	return max(1, int(float64(b.GetWeight()*weightScale)*b.GetSlowStartFactor()))
+}
+
+// effectiveLoad returns the active connections of the backend plus one, scaled up while the backend is in slow start,
+// so load based strategies send a recovered backend a growing share of requests instead of flooding it.
+func effectiveLoad(b backend.Backend) float64 {
+	return float64(b.GetActiveConnections()+1) / b.GetSlowStartFactor()
+}
+
+// admitSlowStart reports whether a backend takes the request, for strategies that do not weigh backends.
+// Backends in slow start take a request with a probability equal to their slow start factor.
+func admitSlowStart(b backend.Backend) bool {
+	f := b.GetSlowStartFactor()
+	return f >= 1 || rand.Float64() < f
+}
@@ -0,0 +1,63 @@
+package serverpool
+
+import (
+	"load-balancer/backend"
+	"load-balancer/utils"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test effective weight and load follow the slow start factor
+func TestEffectiveWeightAndLoad(t *testing.T) {
+	sp, err := NewServerPool(utils.RoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	// A backend that just recovered, in slow start for long enough to stay near its start
+	u, err := url.Parse("http://127.0.0.1:8081")
+	require.NoError(t, err, "failed to parse url")
+	warming := backend.NewBackendWithOptions(u, backend.Options{SlowStart: time.Hour})
+	warming.SetAlive(false)
+	warming.SetAlive(true)
+	sp.AddBackend(warming)
+	full := addBackends(t, sp, "http://127.0.0.1:8082")[0]
+
+	assert.Equal(t, weightScale, effectiveWeight(full))
+	assert.Equal(t, weightScale/10, effectiveWeight(warming))
+
+	assert.Equal(t, 1.0, effectiveLoad(full))
+	assert.InDelta(t, 10.0, effectiveLoad(warming), 0.01)
+}
+
+// Test every strategy still selects a backend in slow start when it is the only one left
+func TestSlowStart_OnlyBackend(t *testing.T) {
+	strategies := []utils.LBStrategy{
+		utils.RoundRobin,
+		utils.LeastConnected,
+		utils.WeightedRoundRobin,
+		utils.ConsistentHash,
+		utils.PowerOfTwoChoices,
+		utils.LeastLatency,
+	}
+
+	for _, strategy := range strategies {
+		sp, err := NewServerPool(strategy)
+		require.NoError(t, err, "failed to create server pool")
+
+		u, err := url.Parse("http://127.0.0.1:8081")
+		require.NoError(t, err, "failed to parse url")
+		warming := backend.NewBackendWithOptions(u, backend.Options{SlowStart: time.Hour})
+		warming.SetAlive(false)
+		warming.SetAlive(true)
+		sp.AddBackend(warming)
+		full := addBackends(t, sp, "http://127.0.0.1:8082")[0]
+		full.SetAlive(false)
+
+		for i := 0; i < 20; i++ {
+			assert.Equal(t, warming, sp.GetNextValidPeer(requestFrom("10.0.0.1")), "strategy %d", strategy)
+		}
+	}
+}
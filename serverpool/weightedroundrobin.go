@@ -0,0 +1,66 @@
+package serverpool
+
+import (
+	"load-balancer/backend"
+	"net/http"
+)
+
+// wrrServerPool implements the ServerPool interface with smooth weighted round-robin strategy.
+// It uses the nginx algorithm so that heavier backends are interleaved with lighter ones instead of being hit in bursts.
+type wrrServerPool struct {
+	backendList
+	currentWeights []int // running weight of each backend, indexed like backends
+}
+
+// GetNextValidPeer returns the next alive backend server using smooth weighted round-robin.
+// On every call each alive backend's current weight grows by its configured weight, the backend
+// with the highest current weight is selected and its current weight is reduced by the total.
+// Returns nil if there is no alive backend found.
+func (s *wrrServerPool) GetNextValidPeer(r *http.Request) backend.Backend {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	best := -1
+	total := 0
+
+	for i, b := range s.backends {
+		// Skip backends that are not alive or excluded
+		if !IsSelectable(r, b) {
+			continue
+		}
+
+		weight := effectiveWeight(b)
+		s.currentWeights[i] += weight
+		total += weight
+
+		if best == -1 || s.currentWeights[i] > s.currentWeights[best] {
+			best = i
+		}
+	}
+
+	if best == -1 {
+		return nil
+	}
+
+	s.currentWeights[best] -= total
+	return s.backends[best]
+}
+
+// AddBackend adds new backend server to the weighted round-robin pool.
+func (s *wrrServerPool) AddBackend(b backend.Backend) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	u := b.GetURL().String()
+	if _, exists := s.backendMap[u]; exists {
+		return
+	}
+	s.backends = append(s.backends, b)
+	s.currentWeights = append(s.currentWeights, 0)
+	s.backendMap[u] = struct{}{}
+}
+
+// removed drops the running weight of the backend removed at index i.
+// The caller holds the lock.
+func (s *wrrServerPool) removed(i int) {
+	s.currentWeights = append(s.currentWeights[:i], s.currentWeights[i+1:]...)
+}
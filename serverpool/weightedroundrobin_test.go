@@ -0,0 +1,194 @@
+package serverpool
+
+import (
+	"load-balancer/backend"
+	"load-balancer/utils"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test smooth interleaving (nginx sequence for weights 5, 1, 1)
+func TestWeightedRoundRobin_SmoothSequence(t *testing.T) {
+	sp, err := NewServerPool(utils.WeightedRoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	weights := []int{5, 1, 1}
+	backends := []backend.Backend{}
+
+	for i, w := range weights {
+		u, err := url.Parse("http://127.0.0.1:808" + strconv.Itoa(i+1))
+		require.NoError(t, err, "failed to parse url")
+		b := backend.NewBackend(u)
+		b.SetWeight(w)
+		sp.AddBackend(b)
+		backends = append(backends, b)
+	}
+
+	a, b, c := backends[0], backends[1], backends[2]
+	expected := []backend.Backend{a, a, b, a, c, a, a}
+
+	// The sequence repeats every sum(weights) picks
+	for round := 0; round < 3; round++ {
+		for i, want := range expected {
+			peer := sp.GetNextValidPeer(nil)
+			assert.Equal(t, want, peer, "round %d pick %d", round, i)
+		}
+	}
+}
+
+// Test distribution matches weights
+func TestWeightedRoundRobin_Distribution(t *testing.T) {
+	sp, err := NewServerPool(utils.WeightedRoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	weights := []int{1, 4, 8}
+	for i, w := range weights {
+		u, err := url.Parse("http://127.0.0.1:808" + strconv.Itoa(i+1))
+		require.NoError(t, err, "failed to parse url")
+		b := backend.NewBackend(u)
+		b.SetWeight(w)
+		sp.AddBackend(b)
+	}
+
+	counts := map[string]int{}
+	for i := 0; i < 13*10; i++ {
+		peer := sp.GetNextValidPeer(nil)
+		counts[peer.GetURL().String()]++
+	}
+
+	assert.Equal(t, 10, counts["http://127.0.0.1:8081"])
+	assert.Equal(t, 40, counts["http://127.0.0.1:8082"])
+	assert.Equal(t, 80, counts["http://127.0.0.1:8083"])
+}
+
+// Test default weight behaves like plain round-robin
+func TestWeightedRoundRobin_EqualWeights(t *testing.T) {
+	sp, err := NewServerPool(utils.WeightedRoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	backends := []backend.Backend{}
+	for i := 0; i < 3; i++ {
+		u, err := url.Parse("http://127.0.0.1:808" + strconv.Itoa(i+1))
+		require.NoError(t, err, "failed to parse url")
+		b := backend.NewBackend(u)
+		sp.AddBackend(b)
+		backends = append(backends, b)
+	}
+
+	for i := 0; i < 9; i++ {
+		assert.Equal(t, backends[i%len(backends)], sp.GetNextValidPeer(nil))
+	}
+}
+
+// Test empty pool
+func TestWeightedRoundRobin_EmptyPool(t *testing.T) {
+	sp, err := NewServerPool(utils.WeightedRoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	assert.Nil(t, sp.GetNextValidPeer(nil))
+}
+
+// Test skipping dead backend with the highest weight
+func TestWeightedRoundRobin_SkipDeadBackend(t *testing.T) {
+	sp, err := NewServerPool(utils.WeightedRoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	u1, err := url.Parse("http://127.0.0.1:8081")
+	require.NoError(t, err, "failed to parse url 1")
+	b1 := backend.NewBackend(u1)
+	sp.AddBackend(b1)
+
+	u2, err := url.Parse("http://127.0.0.1:8082")
+	require.NoError(t, err, "failed to parse url 2")
+	b2 := backend.NewBackend(u2)
+	b2.SetWeight(10)
+	b2.SetAlive(false)
+	sp.AddBackend(b2)
+
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, b1, sp.GetNextValidPeer(nil)) // should skip b2
+	}
+
+	b2.SetAlive(true)
+	b1.SetAlive(false)
+	assert.Equal(t, b2, sp.GetNextValidPeer(nil))
+
+	b2.SetAlive(false)
+	assert.Nil(t, sp.GetNextValidPeer(nil))
+}
+
+// Test concurrent access
+func TestWeightedRoundRobin_ConcurrentAccess(t *testing.T) {
+	sp, err := NewServerPool(utils.WeightedRoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			u, err := url.Parse("http://127.0.0.1:808" + strconv.Itoa(i))
+			require.NoError(t, err, "failed to parse url")
+			b := backend.NewBackend(u)
+			b.SetWeight(i + 1)
+			sp.AddBackend(b)
+		}(i)
+	}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sp.GetNextValidPeer(nil)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 5, sp.GetServerPoolSize())
+}
+
+// Test attempting to add duplicate url
+func TestWeightedRoundRobin_AddDuplicate(t *testing.T) {
+	sp, err := NewServerPool(utils.WeightedRoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	u, err := url.Parse("http://127.0.0.1:8081")
+	require.NoError(t, err, "failed to parse url")
+	b := backend.NewBackend(u)
+
+	sp.AddBackend(b)
+	sp.AddBackend(b) // should be ignored
+
+	backends := sp.GetBackends()
+	assert.Len(t, backends, 1)
+}
+
+// Test the effective weight of a backend in slow start is scaled by its factor
+func TestWeightedRoundRobin_SlowStart(t *testing.T) {
+	sp, err := NewServerPool(utils.WeightedRoundRobin)
+	require.NoError(t, err, "failed to create server pool")
+
+	u, err := url.Parse("http://127.0.0.1:8081")
+	require.NoError(t, err, "failed to parse url")
+	warming := backend.NewBackendWithOptions(u, backend.Options{SlowStart: time.Hour})
+	warming.SetAlive(false)
+	warming.SetAlive(true)
+	sp.AddBackend(warming)
+	addBackends(t, sp, "http://127.0.0.1:8082")
+
+	// Effective weights are 10 and 100 weightScale units
+	picked := 0
+	for i := 0; i < 110; i++ {
+		if sp.GetNextValidPeer(nil) == warming {
+			picked++
+		}
+	}
+
+	assert.Equal(t, 10, picked)
+}
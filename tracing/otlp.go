@@ -0,0 +1,166 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// OTLP/JSON encoding of an export request, see opentelemetry-proto ExportTraceServiceRequest.
+// Trace and span ids are hex encoded and 64-bit integers are strings, as the OTLP JSON mapping requires.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Flags             uint32         `json:"flags"`
+		Name              string         `json:"name"`
+		Kind              SpanKind       `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"` // 0 unset, 2 error
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+	otlpAnyValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+)
+
+// otlpStatusError is the OTLP status code of a failed span.
+const otlpStatusError = 2
+
+// scopeName is the instrumentation scope of every span.
+const scopeName = "load-balancer"
+
+// exporter sends spans to an OTLP/HTTP collector.
+type exporter struct {
+	endpoint string
+	headers  map[string]string
+	resource otlpResource
+	client   *http.Client
+}
+
+func newExporter(opts Options) *exporter {
+	return &exporter{
+		endpoint: opts.Endpoint,
+		headers:  opts.Headers,
+		resource: otlpResource{Attributes: []otlpKeyValue{keyValue("service.name", opts.ServiceName)}},
+		client:   &http.Client{},
+	}
+}
+
+// export posts the spans to the collector in a single request.
+func (e *exporter) export(ctx context.Context, spans []*Span) error {
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   e.resource,
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: scopeName}, Spans: encodeSpans(spans)}},
+	}}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("collector answered %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func encodeSpans(spans []*Span) []otlpSpan {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		s.mux.Lock()
+		span := otlpSpan{
+			TraceID:           s.sc.TraceID.String(),
+			SpanID:            s.sc.SpanID.String(),
+			Flags:             uint32(s.sc.Flags),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if s.parent.IsValid() {
+			span.ParentSpanID = s.parent.String()
+		}
+		for _, a := range s.attributes {
+			span.Attributes = append(span.Attributes, keyValue(a.key, a.value))
+		}
+		if s.err != "" {
+			span.Status = otlpStatus{Code: otlpStatusError, Message: s.err}
+		}
+		s.mux.Unlock()
+
+		out = append(out, span)
+	}
+	return out
+}
+
+// keyValue encodes an attribute, values of unsupported types are formatted as strings.
+func keyValue(key string, value any) otlpKeyValue {
+	var v otlpAnyValue
+	switch value := value.(type) {
+	case string:
+		v.StringValue = &value
+	case int:
+		s := strconv.Itoa(value)
+		v.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(value, 10)
+		v.IntValue = &s
+	case bool:
+		v.BoolValue = &value
+	case float64:
+		v.DoubleValue = &value
+	default:
+		s := fmt.Sprint(value)
+		v.StringValue = &s
+	}
+	return otlpKeyValue{Key: key, Value: v}
+}
@@ -0,0 +1,110 @@
+package tracing
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// TraceparentHeader is the W3C Trace Context header carrying the span context.
+const TraceparentHeader = "traceparent"
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether the id is not all zeros.
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// IsValid reports whether the id is not all zeros.
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// flagSampled is the trace flag asking for the trace to be recorded.
+const flagSampled byte = 0x01
+
+// SpanContext is the part of a span propagated to other services.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Flags   byte
+}
+
+// IsValid reports whether both ids are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// IsSampled reports whether the trace is recorded.
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&flagSampled != 0
+}
+
+// Traceparent returns the version 00 traceparent header value of the span context.
+func (sc SpanContext) Traceparent() string {
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + hex.EncodeToString([]byte{sc.Flags})
+}
+
+// ParseTraceparent parses a traceparent header value.
+// Versions above 00 are accepted as long as their first four fields follow version 00.
+func ParseTraceparent(s string) (SpanContext, bool) {
+	var sc SpanContext
+
+	parts := strings.SplitN(strings.TrimSpace(s), "-", 5)
+	if len(parts) < 4 {
+		return sc, false
+	}
+
+	version, ok := decodeHex(parts[0], 1)
+	if !ok || version[0] == 0xff {
+		return sc, false
+	}
+	if version[0] == 0 && len(parts) != 4 {
+		return sc, false
+	}
+
+	traceID, ok := decodeHex(parts[1], len(sc.TraceID))
+	if !ok {
+		return sc, false
+	}
+	spanID, ok := decodeHex(parts[2], len(sc.SpanID))
+	if !ok {
+		return sc, false
+	}
+	flags, ok := decodeHex(parts[3], 1)
+	if !ok {
+		return sc, false
+	}
+
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Flags = flags[0]
+	return sc, sc.IsValid()
+}
+
+// decodeHex decodes exactly n bytes of lowercase hex.
+func decodeHex(s string, n int) ([]byte, bool) {
+	if len(s) != 2*n || strings.ToLower(s) != s {
+		return nil, false
+	}
+	b, err := hex.DecodeString(s)
+	return b, err == nil
+}
+
+// Extract returns the span context of the traceparent header, false if it is missing or invalid.
+func Extract(h http.Header) (SpanContext, bool) {
+	values := h.Values(TraceparentHeader)
+	if len(values) != 1 {
+		return SpanContext{}, false
+	}
+	return ParseTraceparent(values[0])
+}
+
+// Inject sets the traceparent header to the span context.
+func Inject(sc SpanContext, h http.Header) {
+	h.Set(TraceparentHeader, sc.Traceparent())
+}
@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// Test a valid traceparent round-trips
+func TestParseTraceparent(t *testing.T) {
+	sc, ok := ParseTraceparent(testTraceparent)
+	require.True(t, ok)
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	assert.True(t, sc.IsSampled())
+	assert.Equal(t, testTraceparent, sc.Traceparent())
+
+	// Future versions may append fields
+	sc, ok = ParseTraceparent("cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-what-the-future-holds")
+	require.True(t, ok)
+	assert.False(t, sc.IsSampled())
+}
+
+// Test malformed values are rejected
+func TestParseTraceparent_Invalid(t *testing.T) {
+	for _, v := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",          // missing flags
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", // version 00 has 4 fields
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",       // forbidden version
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",       // zero trace id
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",       // zero span id
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",       // uppercase
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",        // short trace id
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902bz-01",       // not hex
+		"0-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",        // short version
+	} {
+		_, ok := ParseTraceparent(v)
+		assert.False(t, ok, v)
+	}
+}
+
+// Test a single traceparent header is extracted, repeated headers are ignored
+func TestExtractInject(t *testing.T) {
+	h := http.Header{}
+	_, ok := Extract(h)
+	assert.False(t, ok)
+
+	sc, _ := ParseTraceparent(testTraceparent)
+	Inject(sc, h)
+	got, ok := Extract(h)
+	require.True(t, ok)
+	assert.Equal(t, sc, got)
+
+	h.Add(TraceparentHeader, testTraceparent)
+	_, ok = Extract(h)
+	assert.False(t, ok)
+}
@@ -0,0 +1,322 @@
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// SpanKind is the role of a span, numbered as in OTLP.
+type SpanKind int
+
+const (
+	SpanKindServer SpanKind = 2 // handling an incoming request
+	SpanKindClient SpanKind = 3 // sending a request to another service
+)
+
+// Options configures a tracer.
+type Options struct {
+	Endpoint      string            // OTLP/HTTP traces url, e.g. http://localhost:4318/v1/traces
+	Headers       map[string]string // extra export request headers, e.g. for authentication
+	ServiceName   string            // service.name resource attribute (default load-balancer)
+	SampleRatio   float64           // share of new traces recorded, traces continued from a caller keep its decision (default 1)
+	BatchSize     int               // spans per export request (default 512)
+	QueueSize     int               // spans waiting for export, more are dropped (default 2048)
+	FlushInterval time.Duration     // longest time a span waits for export (default 5s)
+	Timeout       time.Duration     // time allowed for an export request (default 10s)
+	Logger        *zap.Logger       // export failures are logged here (default no-op)
+}
+
+// withDefaults returns the options with defaults applied to unset fields.
+func (o Options) withDefaults() Options {
+	if o.ServiceName == "" {
+		o.ServiceName = "load-balancer"
+	}
+	if o.SampleRatio <= 0 || o.SampleRatio > 1 {
+		o.SampleRatio = 1
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 512
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 2048
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = 5 * time.Second
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+	if o.Logger == nil {
+		o.Logger = zap.NewNop()
+	}
+	return o
+}
+
+// Tracer creates spans and exports the sampled ones over OTLP/HTTP in batches.
+// A nil *Tracer is valid and creates no spans, so tracing can be disabled by passing nil.
+type Tracer struct {
+	opts     Options
+	exporter *exporter
+	queue    chan *Span
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+// NewTracer creates a tracer exporting to the endpoint of the options and starts its export loop.
+func NewTracer(opts Options) *Tracer {
+	opts = opts.withDefaults()
+
+	t := &Tracer{
+		opts:     opts,
+		exporter: newExporter(opts),
+		queue:    make(chan *Span, opts.QueueSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// Start starts a span, child of the span in the context or of the remote span context set with
+// ContextWithRemoteSpanContext, and returns a context holding it. Without a parent a new trace is started.
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	s := &Span{tracer: t, name: name, kind: kind, start: time.Now()}
+
+	parent, ok := parentFromContext(ctx)
+	if ok {
+		s.parent = parent.SpanID
+		s.sc.TraceID = parent.TraceID
+		s.sc.Flags = parent.Flags
+	} else {
+		s.sc.TraceID = newTraceID()
+		if rand.Float64() < t.opts.SampleRatio {
+			s.sc.Flags = flagSampled
+		}
+	}
+	s.sc.SpanID = newSpanID()
+
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// StartRequest starts the server span of an incoming request, continuing the trace of its traceparent header.
+// The returned request carries the span in its context.
+func (t *Tracer) StartRequest(r *http.Request, name string) (*http.Request, *Span) {
+	if t == nil {
+		return r, nil
+	}
+
+	ctx := r.Context()
+	if sc, ok := Extract(r.Header); ok {
+		ctx = ContextWithRemoteSpanContext(ctx, sc)
+	}
+	ctx, span := t.Start(ctx, name, SpanKindServer)
+	return r.WithContext(ctx), span
+}
+
+// Shutdown exports the spans still queued and stops the export loop.
+// Spans ended afterwards are dropped.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	t.once.Do(func() { close(t.stop) })
+	select {
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// enqueue hands an ended span to the export loop, dropping it if the queue is full.
+func (t *Tracer) enqueue(s *Span) {
+	select {
+	case <-t.stop:
+		return
+	default:
+	}
+
+	select {
+	case t.queue <- s:
+	default:
+		t.opts.Logger.Warn("trace export queue full, span dropped", zap.String("span", s.name))
+	}
+}
+
+// run exports queued spans in batches, when a batch is full or every flush interval.
+func (t *Tracer) run() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(t.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, t.opts.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), t.opts.Timeout)
+		if err := t.exporter.export(ctx, batch); err != nil {
+			t.opts.Logger.Error("failed to export spans", zap.Int("spans", len(batch)), zap.Error(err))
+		}
+		cancel()
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case s := <-t.queue:
+			batch = append(batch, s)
+			if len(batch) >= t.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-t.stop:
+			// Export what is left in the queue
+			for {
+				select {
+				case s := <-t.queue:
+					batch = append(batch, s)
+					if len(batch) >= t.opts.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// Span is a timed operation of a trace. A nil *Span ignores every call.
+type Span struct {
+	tracer *Tracer
+	name   string
+	kind   SpanKind
+	sc     SpanContext
+	parent SpanID // zero for a root span
+	start  time.Time
+
+	mux        sync.Mutex
+	end        time.Time
+	ended      bool
+	attributes []attribute
+	err        string // status message, the span failed if set
+}
+
+// attribute is a key/value pair describing a span.
+type attribute struct {
+	key   string
+	value any // string, int, int64, bool or float64
+}
+
+// SpanContext returns the propagated part of the span.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetAttribute sets an attribute of the span. Values must be string, int, int64, bool or float64.
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil {
+		return
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for i := range s.attributes {
+		if s.attributes[i].key == key {
+			s.attributes[i].value = value
+			return
+		}
+	}
+	s.attributes = append(s.attributes, attribute{key: key, value: value})
+}
+
+// SetError marks the span as failed with the error message.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.mux.Lock()
+	s.err = err.Error()
+	s.mux.Unlock()
+}
+
+// End ends the span and queues it for export if its trace is sampled. Only the first call has an effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.mux.Lock()
+	if s.ended {
+		s.mux.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mux.Unlock()
+
+	if s.sc.IsSampled() {
+		s.tracer.enqueue(s)
+	}
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// SpanFromContext returns the span of the context, nil if there is none.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// ContextWithRemoteSpanContext returns a context whose spans continue the trace of a caller.
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// parentFromContext returns the span context new spans are children of.
+func parentFromContext(ctx context.Context) (SpanContext, bool) {
+	if s := SpanFromContext(ctx); s != nil {
+		return s.sc, true
+	}
+	sc, ok := ctx.Value(remoteKey{}).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(id[8:], rand.Uint64())
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:], rand.Uint64())
+	}
+	return id
+}
@@ -0,0 +1,120 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"load-balancer/tracing/tracingtest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test child spans share the trace of their parent and are exported on shutdown
+func TestTracer_Export(t *testing.T) {
+	c := tracingtest.NewCollector(t)
+	tracer := NewTracer(Options{Endpoint: c.Endpoint, ServiceName: "lb-test", Headers: map[string]string{"Authorization": "Bearer x"}})
+
+	ctx, root := tracer.Start(context.Background(), "root", SpanKindServer)
+	root.SetAttribute("http.request.method", "GET")
+	root.SetAttribute("lb.attempts", 2)
+
+	_, child := tracer.Start(ctx, "child", SpanKindClient)
+	child.SetAttribute("lb.retry", 1)
+	child.SetAttribute("sampled", true)
+	child.SetAttribute("ratio", 0.5)
+	child.SetError(errors.New("connection refused"))
+	child.End()
+	child.End() // no effect
+	root.End()
+
+	require.NoError(t, tracer.Shutdown(context.Background()))
+
+	require.Len(t, c.Spans(), 2)
+	r, ch := c.Span("root"), c.Span("child")
+
+	assert.Equal(t, root.SpanContext().TraceID.String(), r.TraceID)
+	assert.Equal(t, r.TraceID, ch.TraceID)
+	assert.Empty(t, r.ParentSpanID)
+	assert.Equal(t, r.SpanID, ch.ParentSpanID)
+	assert.Equal(t, SpanKindServer, SpanKind(r.Kind))
+	assert.Equal(t, SpanKindClient, SpanKind(ch.Kind))
+
+	assert.Equal(t, "GET", *r.Attr("http.request.method").StringValue)
+	assert.Equal(t, "2", *r.Attr("lb.attempts").IntValue)
+	assert.Equal(t, "1", *ch.Attr("lb.retry").IntValue)
+	assert.True(t, *ch.Attr("sampled").BoolValue)
+	assert.Equal(t, 0.5, *ch.Attr("ratio").DoubleValue)
+
+	assert.Equal(t, otlpStatusError, ch.Status.Code)
+	assert.Equal(t, "connection refused", ch.Status.Message)
+	assert.Zero(t, r.Status.Code)
+	assert.NotEqual(t, "0", r.EndTimeUnixNano)
+
+	assert.Equal(t, "Bearer x", c.Header().Get("Authorization"))
+	require.NotEmpty(t, c.Resource())
+	assert.Equal(t, "service.name", c.Resource()[0].Key)
+	assert.Equal(t, "lb-test", c.Resource()[0].Value.String())
+}
+
+// Test a request continues the trace of its traceparent and keeps the caller sampling decision
+func TestTracer_StartRequest(t *testing.T) {
+	c := tracingtest.NewCollector(t)
+	tracer := NewTracer(Options{Endpoint: c.Endpoint})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(TraceparentHeader, testTraceparent)
+	req, span := tracer.StartRequest(req, "GET")
+	span.End()
+
+	assert.Equal(t, span, SpanFromContext(req.Context()))
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID.String())
+
+	// Not sampled by the caller: propagated but not exported
+	unsampled := httptest.NewRequest(http.MethodGet, "/", nil)
+	unsampled.Header.Set(TraceparentHeader, "00-11111111111111111111111111111111-00f067aa0ba902b7-00")
+	_, span = tracer.StartRequest(unsampled, "unsampled")
+	span.End()
+	assert.False(t, span.SpanContext().IsSampled())
+
+	require.NoError(t, tracer.Shutdown(context.Background()))
+
+	require.Len(t, c.Spans(), 1)
+	assert.Equal(t, "00f067aa0ba902b7", c.Span("GET").ParentSpanID)
+}
+
+// Test spans are exported every flush interval, and export failures do not stop the tracer
+func TestTracer_FlushInterval(t *testing.T) {
+	c := tracingtest.NewCollector(t)
+	c.SetStatus(http.StatusServiceUnavailable)
+	tracer := NewTracer(Options{Endpoint: c.Endpoint, FlushInterval: 10 * time.Millisecond})
+	defer tracer.Shutdown(context.Background())
+
+	_, span := tracer.Start(context.Background(), "rejected", SpanKindServer)
+	span.End()
+	assert.Eventually(t, func() bool { return len(c.Spans()) == 1 }, time.Second, 5*time.Millisecond)
+
+	c.SetStatus(http.StatusOK)
+
+	_, span = tracer.Start(context.Background(), "accepted", SpanKindServer)
+	span.End()
+	assert.Eventually(t, func() bool { return len(c.Spans()) == 2 }, time.Second, 5*time.Millisecond)
+}
+
+// Test a nil tracer creates no spans and every span call is safe
+func TestTracer_Nil(t *testing.T) {
+	var tracer *Tracer
+
+	ctx, span := tracer.Start(context.Background(), "noop", SpanKindServer)
+	assert.Nil(t, span)
+	assert.Nil(t, SpanFromContext(ctx))
+
+	span.SetAttribute("k", "v")
+	span.SetError(errors.New("ignored"))
+	span.End()
+	assert.False(t, span.SpanContext().IsValid())
+	assert.NoError(t, tracer.Shutdown(context.Background()))
+}
@@ -0,0 +1,165 @@
+package tracingtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// Decoded OTLP/JSON export request, with the fields checked by the tests.
+type (
+	request struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []KeyValue `json:"attributes"`
+			} `json:"resource"`
+			ScopeSpans []struct {
+				Spans []Span `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+
+	// Span is an exported span.
+	Span struct {
+		TraceID         string     `json:"traceId"`
+		SpanID          string     `json:"spanId"`
+		ParentSpanID    string     `json:"parentSpanId"`
+		Name            string     `json:"name"`
+		Kind            int        `json:"kind"`
+		EndTimeUnixNano string     `json:"endTimeUnixNano"`
+		Attributes      []KeyValue `json:"attributes"`
+		Status          struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"status"`
+	}
+
+	// KeyValue is an exported attribute.
+	KeyValue struct {
+		Key   string   `json:"key"`
+		Value AnyValue `json:"value"`
+	}
+
+	// AnyValue is an exported attribute value, only the field of its type is set.
+	AnyValue struct {
+		StringValue *string  `json:"stringValue"`
+		IntValue    *string  `json:"intValue"`
+		BoolValue   *bool    `json:"boolValue"`
+		DoubleValue *float64 `json:"doubleValue"`
+	}
+)
+
+// Attr returns the value of a span attribute, the zero value if the span does not have it.
+func (s Span) Attr(key string) AnyValue {
+	for _, kv := range s.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return AnyValue{}
+}
+
+// String returns the value as text, or an empty string if it is unset.
+func (v AnyValue) String() string {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.IntValue != nil:
+		return *v.IntValue
+	case v.BoolValue != nil:
+		return strconv.FormatBool(*v.BoolValue)
+	case v.DoubleValue != nil:
+		return strconv.FormatFloat(*v.DoubleValue, 'g', -1, 64)
+	}
+	return ""
+}
+
+// Collector is a local OTLP/HTTP endpoint recording the exported spans.
+type Collector struct {
+	Endpoint string // traces url to export to
+
+	mux      sync.Mutex
+	requests []request
+	header   http.Header
+	status   int
+}
+
+// NewCollector starts a collector answering 200, closed when the test ends.
+func NewCollector(t testing.TB) *Collector {
+	t.Helper()
+
+	c := &Collector{status: http.StatusOK}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" ||
+			json.NewDecoder(r.Body).Decode(&req) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		c.mux.Lock()
+		defer c.mux.Unlock()
+		c.requests = append(c.requests, req)
+		c.header = r.Header
+		w.WriteHeader(c.status)
+	}))
+	t.Cleanup(s.Close)
+
+	c.Endpoint = s.URL + "/v1/traces"
+	return c
+}
+
+// SetStatus sets the status code answered to the next exports, recorded all the same.
+func (c *Collector) SetStatus(code int) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.status = code
+}
+
+// Spans returns every exported span, in export order.
+func (c *Collector) Spans() []Span {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	var spans []Span
+	for _, req := range c.requests {
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}
+	return spans
+}
+
+// Span returns the last exported span with the given name, the zero value if there is none.
+func (c *Collector) Span(name string) Span {
+	var span Span
+	for _, s := range c.Spans() {
+		if s.Name == name {
+			span = s
+		}
+	}
+	return span
+}
+
+// Header returns the headers of the last export.
+func (c *Collector) Header() http.Header {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.header
+}
+
+// Resource returns the resource attributes of the first export.
+func (c *Collector) Resource() []KeyValue {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if len(c.requests) == 0 || len(c.requests[0].ResourceSpans) == 0 {
+		return nil
+	}
+	return c.requests[0].ResourceSpans[0].Resource.Attributes
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"errors"
+	"fmt"
 	"os"
 
 	"gopkg.in/yaml.v3"
@@ -12,33 +13,279 @@ type LBStrategy int
 const (
 	RoundRobin LBStrategy = iota
 	LeastConnected
+	WeightedRoundRobin
+	ConsistentHash
+	PowerOfTwoChoices
+	LeastLatency
 )
 
+// GetLBStrategy returns the strategy of a name, round-robin for unknown names.
 func GetLBStrategy(strategy string) LBStrategy {
+	s, err := ParseLBStrategy(strategy)
+	if err != nil {
+		return RoundRobin
+	}
+	return s
+}
+
+// ParseLBStrategy returns the strategy of a name, round-robin when empty.
+// Returns an error if the name is unknown.
+func ParseLBStrategy(strategy string) (LBStrategy, error) {
 	switch strategy {
+	case "", "round-robin":
+		return RoundRobin, nil
 	case "least-connection":
-		return LeastConnected
+		return LeastConnected, nil
+	case "weighted-round-robin":
+		return WeightedRoundRobin, nil
+	case "consistent-hash":
+		return ConsistentHash, nil
+	case "p2c":
+		return PowerOfTwoChoices, nil
+	case "least-latency":
+		return LeastLatency, nil
 	default:
-		return RoundRobin
+		return RoundRobin, fmt.Errorf("unknown strategy %q", strategy)
 	}
 }
 
+// BackendConfig describes a single backend entry in config.yaml.
+// An entry can be a plain URL string or a mapping with a url, a weight and health check overrides.
+type BackendConfig struct {
+	URL         string            `yaml:"url"`
+	Weight      int               `yaml:"weight"`
+	HealthCheck HealthCheckConfig `yaml:"health_check"` // merged over the pool health check
+}
+
+// HealthCheckConfig configures the active health check.
+type HealthCheckConfig struct {
+	Type    string `yaml:"type"`    // http (default), tcp or grpc
+	Service string `yaml:"service"` // grpc service name, empty checks the whole server
+
+	Path           string            `yaml:"path"`
+	Method         string            `yaml:"method"`
+	Headers        map[string]string `yaml:"headers"`
+	Host           string            `yaml:"host"`            // Host header override
+	ExpectedStatus []string          `yaml:"expected_status"` // codes ("204") or ranges ("200-299")
+	BodyContains   string            `yaml:"body_contains"`
+	BodyRegex      string            `yaml:"body_regex"`
+
+	HealthyThreshold   int `yaml:"healthy_threshold"`   // consecutive successes to mark a backend up
+	UnhealthyThreshold int `yaml:"unhealthy_threshold"` // consecutive failures to mark a backend down
+	FlapThreshold      int `yaml:"flap_threshold"`      // state changes allowed within the flap window, 0 disables
+	FlapWindow         int `yaml:"flap_window"`         // in seconds
+	HoldDown           int `yaml:"hold_down"`           // in seconds, time a flapping backend stays down
+}
+
+// merge returns c with the fields set in override replaced.
+func (c HealthCheckConfig) merge(override HealthCheckConfig) HealthCheckConfig {
+	if override.Type != "" {
+		c.Type = override.Type
+	}
+	if override.Service != "" {
+		c.Service = override.Service
+	}
+	if override.Path != "" {
+		c.Path = override.Path
+	}
+	if override.Method != "" {
+		c.Method = override.Method
+	}
+	if len(override.Headers) > 0 {
+		headers := make(map[string]string, len(c.Headers)+len(override.Headers))
+		for k, v := range c.Headers {
+			headers[k] = v
+		}
+		for k, v := range override.Headers {
+			headers[k] = v
+		}
+		c.Headers = headers
+	}
+	if override.Host != "" {
+		c.Host = override.Host
+	}
+	if len(override.ExpectedStatus) > 0 {
+		c.ExpectedStatus = override.ExpectedStatus
+	}
+	if override.BodyContains != "" {
+		c.BodyContains = override.BodyContains
+	}
+	if override.BodyRegex != "" {
+		c.BodyRegex = override.BodyRegex
+	}
+	if override.HealthyThreshold > 0 {
+		c.HealthyThreshold = override.HealthyThreshold
+	}
+	if override.UnhealthyThreshold > 0 {
+		c.UnhealthyThreshold = override.UnhealthyThreshold
+	}
+	if override.FlapThreshold > 0 {
+		c.FlapThreshold = override.FlapThreshold
+	}
+	if override.FlapWindow > 0 {
+		c.FlapWindow = override.FlapWindow
+	}
+	if override.HoldDown > 0 {
+		c.HoldDown = override.HoldDown
+	}
+	return c
+}
+
+// UnmarshalYAML accepts both the short form ("http://host:port") and the long form ({url: ..., weight: ...}).
+func (b *BackendConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		b.URL = value.Value
+		return nil
+	}
+
+	type plain BackendConfig // avoid recursing into UnmarshalYAML
+	return value.Decode((*plain)(b))
+}
+
+// ConsistentHashConfig configures the consistent-hash strategy.
+type ConsistentHashConfig struct {
+	Key          string `yaml:"key"`           // "ip", "path", "header:<name>" or "cookie:<name>"
+	VirtualNodes int    `yaml:"virtual_nodes"` // ring points per backend
+}
+
+// StickySessionConfig configures cookie based session affinity.
+type StickySessionConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	CookieName string `yaml:"cookie_name"`
+	TTL        int    `yaml:"ttl"`         // seconds
+	SigningKey string `yaml:"signing_key"` // HMAC key, a random key is generated when empty
+}
+
+// RetryConfig configures when a failed request may be retried on another backend.
+type RetryConfig struct {
+	MaxBodySize    int64    `yaml:"max_body_size"`   // bytes buffered for replay, larger bodies are not retried
+	IdempotentOnly bool     `yaml:"idempotent_only"` // skip retries for non-idempotent methods (POST, PATCH, ...)
+	Statuses       []int    `yaml:"statuses"`        // upstream status codes retried on another backend
+	Methods        []string `yaml:"methods"`         // methods retried on a retryable status
+	PerTryTimeout  int      `yaml:"per_try_timeout"` // seconds allowed for one attempt at a backend, 0 disables
+}
+
+// OutlierDetectionConfig configures passive health checking of live traffic.
+type OutlierDetectionConfig struct {
+	ConsecutiveFailures int `yaml:"consecutive_failures"` // consecutive 5xx responses or errors before ejection
+	BaseEjectionTime    int `yaml:"base_ejection_time"`   // seconds, doubled on every further ejection
+	MaxEjectionTime     int `yaml:"max_ejection_time"`    // seconds
+	MaxEjectionPercent  int `yaml:"max_ejection_percent"` // maximum share of the pool ejected at once
+}
+
+// CircuitBreakerConfig configures the per-backend circuit breaker.
+type CircuitBreakerConfig struct {
+	Enabled              bool    `yaml:"enabled"`
+	FailureRateThreshold float64 `yaml:"failure_rate_threshold"` // failure ratio that opens the circuit
+	MinimumRequests      int     `yaml:"minimum_requests"`       // requests in the window before the rate is evaluated
+	Window               int     `yaml:"window"`                 // seconds
+	OpenTimeout          int     `yaml:"open_timeout"`           // seconds spent open before probing
+	HalfOpenProbes       int     `yaml:"half_open_probes"`       // probe requests allowed in half-open
+}
+
+// AdminConfig configures the admin API listener.
+type AdminConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Port    int    `yaml:"port"`  // must differ from lb_port
+	Token   string `yaml:"token"` // bearer token, required when enabled
+}
+
+// AccessLogConfig configures the access log of proxied requests.
+type AccessLogConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	Format     string `yaml:"format"`      // json | common | combined | template
+	Template   string `yaml:"template"`    // text/template on the access log entry, used by the template format
+	Output     string `yaml:"output"`      // stdout, stderr or a file path
+	MaxSize    int    `yaml:"max_size"`    // megabytes before the file is rotated, 0 disables rotation
+	MaxBackups int    `yaml:"max_backups"` // rotated files kept
+}
+
+// TracingConfig configures the export of request traces.
+type TracingConfig struct {
+	Enabled     bool              `yaml:"enabled"`
+	Endpoint    string            `yaml:"endpoint"`     // OTLP/HTTP traces url
+	Headers     map[string]string `yaml:"headers"`      // extra export request headers
+	ServiceName string            `yaml:"service_name"` // service.name of the spans
+	SampleRatio float64           `yaml:"sample_ratio"` // share of new traces recorded, in (0, 1]
+}
+
+// ForwardedConfig configures the forwarding headers sent to backends and how the client address is resolved.
+type ForwardedConfig struct {
+	Mode           string   `yaml:"mode"`            // append | overwrite
+	Forwarded      bool     `yaml:"forwarded"`       // also send the RFC 7239 Forwarded header
+	TrustedProxies []string `yaml:"trusted_proxies"` // CIDR ranges or addresses whose forwarding headers are believed
+}
+
 type Config struct {
-	Port                int      `yaml:"lb_port"`
-	MaxAttemptLimit     int      `yaml:"max_attempt_limit"`
-	Backends            []string `yaml:"backends"`
-	Strategy            string   `yaml:"strategy"`
-	HealthCheckInterval int      `yaml:"healthcheck_interval"`
-	BackendTimeout      int      `yaml:"backend_timeout"`
-	ShutdownTimeout     int      `yaml:"shutdown_timeout"`
+	Port                    int                    `yaml:"lb_port"`
+	MaxAttemptLimit         int                    `yaml:"max_attempt_limit"`
+	Backends                []BackendConfig        `yaml:"backends"`
+	Strategy                string                 `yaml:"strategy"`
+	ConsistentHash          ConsistentHashConfig   `yaml:"consistent_hash"`
+	StickySession           StickySessionConfig    `yaml:"sticky_session"`
+	Retry                   RetryConfig            `yaml:"retry"`
+	OutlierDetection        OutlierDetectionConfig `yaml:"outlier_detection"`
+	CircuitBreaker          CircuitBreakerConfig   `yaml:"circuit_breaker"`
+	HealthCheckInterval     int                    `yaml:"healthcheck_interval"`
+	HealthCheckJitter       float64                `yaml:"healthcheck_jitter"`        // fraction of the interval, negative disables
+	HealthCheckBackoffAfter int                    `yaml:"healthcheck_backoff_after"` // in seconds
+	HealthCheckMaxInterval  int                    `yaml:"healthcheck_max_interval"`  // in seconds
+	HealthCheck             HealthCheckConfig      `yaml:"health_check"`
+	SlowStart               int                    `yaml:"slow_start"` // in seconds, 0 disables slow start
+	BackendTimeout          int                    `yaml:"backend_timeout"`
+	ShutdownTimeout         int                    `yaml:"shutdown_timeout"`
+	DrainTimeout            int                    `yaml:"drain_timeout"`   // in seconds
+	ReloadInterval          int                    `yaml:"reload_interval"` // in seconds, polling of the config file, 0 disables
+	Admin                   AdminConfig            `yaml:"admin"`
+	AccessLog               AccessLogConfig        `yaml:"access_log"`
+	Tracing                 TracingConfig          `yaml:"tracing"`
+	ForwardedHeaders        ForwardedConfig        `yaml:"forwarded_headers"`
 }
 
+// MAX_LB_ATTEMPTS is the default number of backends tried per request.
 const MAX_LB_ATTEMPTS int = 3
 
+// BackendDefaults returns the backend configuration with the pool health check applied below
+// its overrides and defaults set for unset fields.
+func (c *Config) BackendDefaults(bc BackendConfig) BackendConfig {
+	// set backend weight if not configured
+	if bc.Weight <= 0 {
+		bc.Weight = 1
+	}
+	// apply the pool health check below the backend overrides
+	hc := c.HealthCheck.merge(bc.HealthCheck)
+
+	// set health thresholds if not configured
+	if hc.HealthyThreshold <= 0 {
+		hc.HealthyThreshold = 1
+	}
+	if hc.UnhealthyThreshold <= 0 {
+		hc.UnhealthyThreshold = 1
+	}
+	if hc.FlapWindow <= 0 {
+		hc.FlapWindow = 60 // default to 1 minute
+	}
+	if hc.HoldDown <= 0 {
+		hc.HoldDown = 120 // default to 2 minutes
+	}
+	bc.HealthCheck = hc
+
+	return bc
+}
+
+// ConfigPath is the configuration file read by GetLBConfig.
+const ConfigPath = "config.yaml"
+
+// GetLBConfig reads the load balancer configuration from ConfigPath.
 func GetLBConfig() (*Config, error) {
+	return LoadLBConfig(ConfigPath)
+}
+
+// LoadLBConfig reads the load balancer configuration from the file at path, validates it and applies defaults.
+func LoadLBConfig(path string) (*Config, error) {
 	var config Config
 
-	configFile, err := os.ReadFile("config.yaml")
+	configFile, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
@@ -52,27 +299,135 @@ func GetLBConfig() (*Config, error) {
 		return nil, errors.New("backend hosts expected, none provided")
 	}
 
+	for i := range config.Backends {
+		if config.Backends[i].URL == "" {
+			return nil, errors.New("backend url expected, none provided")
+		}
+		config.Backends[i] = config.BackendDefaults(config.Backends[i])
+	}
+
 	if config.Port == 0 {
 		return nil, errors.New("load balancer port not found")
 	}
 
+	// a misspelled strategy must not fall back to round-robin silently
+	if _, err := ParseLBStrategy(config.Strategy); err != nil {
+		return nil, err
+	}
+
 	// set health timeout if not configured
 	if config.HealthCheckInterval <= 0 {
 		config.HealthCheckInterval = 20 // default to 20 seconds
 	}
 
+	// set health check jitter and backoff if not configured
+	if config.HealthCheckJitter == 0 {
+		config.HealthCheckJitter = 0.1
+	}
+	if config.HealthCheckBackoffAfter <= 0 {
+		config.HealthCheckBackoffAfter = 60 // default to 1 minute
+	}
+	if config.HealthCheckMaxInterval <= 0 {
+		config.HealthCheckMaxInterval = 300 // default to 5 minutes
+	}
+
 	// set backend timeout if not configured
 	if config.BackendTimeout <= 0 {
 		config.BackendTimeout = 2 // default to 2 seconds
 	}
 
+	// set consistent hash defaults if not configured
+	if config.ConsistentHash.Key == "" {
+		config.ConsistentHash.Key = "ip"
+	}
+	if config.ConsistentHash.VirtualNodes <= 0 {
+		config.ConsistentHash.VirtualNodes = 100
+	}
+
+	// set retry body buffer size if not configured
+	if config.Retry.MaxBodySize <= 0 {
+		config.Retry.MaxBodySize = 1 << 20 // default to 1 MiB
+	}
+
+	// set retryable statuses and methods if not configured
+	if config.Retry.Statuses == nil {
+		config.Retry.Statuses = []int{502, 503, 504}
+	}
+	if config.Retry.Methods == nil {
+		config.Retry.Methods = []string{"GET", "HEAD", "OPTIONS", "PUT", "DELETE"}
+	}
+
+	// set outlier detection defaults if not configured
+	if config.OutlierDetection.ConsecutiveFailures <= 0 {
+		config.OutlierDetection.ConsecutiveFailures = 5
+	}
+	if config.OutlierDetection.BaseEjectionTime <= 0 {
+		config.OutlierDetection.BaseEjectionTime = 30 // default to 30 seconds
+	}
+	if config.OutlierDetection.MaxEjectionTime <= 0 {
+		config.OutlierDetection.MaxEjectionTime = 300 // default to 5 minutes
+	}
+	if config.OutlierDetection.MaxEjectionPercent <= 0 {
+		config.OutlierDetection.MaxEjectionPercent = 50
+	}
+
+	// set sticky session defaults if not configured
+	if config.StickySession.CookieName == "" {
+		config.StickySession.CookieName = "lb_backend"
+	}
+	if config.StickySession.TTL <= 0 {
+		config.StickySession.TTL = 3600 // default to 1 hour
+	}
+
 	if config.ShutdownTimeout <= 0 {
 		config.ShutdownTimeout = 10 // default to 2 seconds
 	}
 
 	// set max attempt limit if not configured
 	if config.MaxAttemptLimit <= 0 {
-		config.MaxAttemptLimit = 3
+		config.MaxAttemptLimit = MAX_LB_ATTEMPTS
+	}
+
+	// set drain timeout if not configured
+	if config.DrainTimeout <= 0 {
+		config.DrainTimeout = 30 // default to 30 seconds
+	}
+
+	// set access log defaults if not configured
+	if config.AccessLog.Format == "" {
+		config.AccessLog.Format = "json"
+	}
+	if config.AccessLog.Output == "" {
+		config.AccessLog.Output = "stdout"
+	}
+	if config.AccessLog.MaxBackups < 0 {
+		config.AccessLog.MaxBackups = 0
+	}
+
+	// set tracing defaults if not configured
+	if config.Tracing.Endpoint == "" {
+		config.Tracing.Endpoint = "http://localhost:4318/v1/traces"
+	}
+	if config.Tracing.ServiceName == "" {
+		config.Tracing.ServiceName = "load-balancer"
+	}
+	if config.Tracing.SampleRatio <= 0 || config.Tracing.SampleRatio > 1 {
+		config.Tracing.SampleRatio = 1
+	}
+
+	// set forwarded headers defaults if not configured
+	if config.ForwardedHeaders.Mode == "" {
+		config.ForwardedHeaders.Mode = "append"
+	}
+
+	// the admin API must be protected and kept off the load balancer port
+	if config.Admin.Enabled {
+		if config.Admin.Token == "" {
+			return nil, errors.New("admin token expected, none provided")
+		}
+		if config.Admin.Port == 0 || config.Admin.Port == config.Port {
+			return nil, errors.New("admin port must be set and differ from the load balancer port")
+		}
 	}
 
 	return &config, nil
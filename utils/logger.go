@@ -9,5 +9,6 @@ func InitLogger() *zap.Logger {
 	defer logger.Sync()
 
 	logger.Info("this is an info message")
+	Logger = logger
 	return logger
 }
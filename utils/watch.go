@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// WatchFile polls the file at path every interval and calls onChange when its size or modification time changed.
+// A file that cannot be read is not reported, it is compared again once readable. It exits when the context is canceled.
+func WatchFile(ctx context.Context, path string, interval time.Duration, onChange func()) {
+	var lastSize int64
+	var lastMod time.Time
+	if info, err := os.Stat(path); err == nil {
+		lastSize, lastMod = info.Size(), info.ModTime()
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if info.Size() == lastSize && info.ModTime().Equal(lastMod) {
+			continue
+		}
+
+		lastSize, lastMod = info.Size(), info.ModTime()
+		onChange()
+	}
+}